@@ -0,0 +1,95 @@
+package dom
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// AttrNotFound is returned by the typed attribute getters when the attribute is absent.
+var AttrNotFound = errors.New("attribute not found")
+
+// SetAttr sets the value of the attribute with the given name, adding the
+// attribute if it is not already present.
+// The altered node is returned.
+func (node *Element) SetAttr(name, space, value string) *Element {
+	return node.AddAttr(Attr(name, space, value))
+}
+
+// RemoveAttr removes the matching attributes from node.  As with [Element.GetAttr],
+// name or space may be "*" to match anything.
+// The altered node is returned.
+func (node *Element) RemoveAttr(name, space string) *Element {
+	node.Attributes = slices.DeleteFunc(node.Attributes, func(a xml.Attr) bool {
+		return attrMatches(a, name, space)
+	})
+	node.changed()
+	return node
+}
+
+// HasAttr returns true if node has a matching attribute.  As with [Element.GetAttr],
+// name or space may be "*" to match anything.
+func (node *Element) HasAttr(name, space string) bool {
+	_, found := node.AttrValue(name, space)
+	return found
+}
+
+// AttrValue returns the value of the first matching attribute.  As with
+// [Element.GetAttr], name or space may be "*" to match anything.  If there is
+// no such attribute, the result is false.
+func (node *Element) AttrValue(name, space string) (string, bool) {
+	for _, a := range node.Attributes {
+		if attrMatches(a, name, space) {
+			return a.Value, true
+		}
+	}
+	return "", false
+}
+
+// AttrInt returns the value of the matching attribute as an int.  The value
+// is an xs:int or similar, with surrounding whitespace allowed.  If the attribute
+// is absent, the error is [AttrNotFound].
+func (node *Element) AttrInt(name, space string) (int, error) {
+	return attrAs(node, name, space, parseInt)
+}
+
+// AttrBool returns the value of the matching attribute as a bool.  The value
+// is an xs:boolean, i.e. "true", "false", "1" or "0".  If the attribute is
+// absent, the error is [AttrNotFound].
+func (node *Element) AttrBool(name, space string) (bool, error) {
+	return attrAs(node, name, space, parseBool)
+}
+
+// AttrFloat returns the value of the matching attribute as a float64.  The
+// value is an xs:double or xs:decimal, e.g. "1.5E3", "INF" or "NaN".  If the
+// attribute is absent, the error is [AttrNotFound].
+func (node *Element) AttrFloat(name, space string) (float64, error) {
+	return attrAs(node, name, space, parseFloat)
+}
+
+// AttrTime returns the value of the matching attribute as a time.  The value
+// is an xs:dateTime or xs:date; if it has no timezone, UTC is assumed.  If the
+// attribute is absent, the error is [AttrNotFound].
+func (node *Element) AttrTime(name, space string) (time.Time, error) {
+	return attrAs(node, name, space, parseTime)
+}
+
+func attrAs[T any](node *Element, name, space string, parse func(string) (T, error)) (T, error) {
+	qname := formatName(xml.Name{Space: space, Local: name})
+	value, found := node.AttrValue(name, space)
+	if !found {
+		var zero T
+		return zero, fmt.Errorf("%s: %w: %s", node.Location(), AttrNotFound, qname)
+	}
+	v, err := parse(value)
+	if err != nil {
+		return v, fmt.Errorf("%s: attribute %s: %w", node.Location(), qname, err)
+	}
+	return v, nil
+}
+
+func attrMatches(a xml.Attr, name, space string) bool {
+	return (name == "*" || name == a.Name.Local) && (space == "*" || space == a.Name.Space)
+}
@@ -0,0 +1,72 @@
+package dom
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestAddAttrReplaces(t *testing.T) {
+	e := Elem("a", "").Attr("x", "", "1").Attr("y", "", "2")
+	e.AddAttr(Attr("x", "", "3"))
+	expect.Slice(e.Attributes).ToHaveLength(t, 2)
+	expect.String(e.Bytes()).ToEqual(t, `<a x="3" y="2"/>`)
+}
+
+func TestSetAndRemoveAttr(t *testing.T) {
+	e := Elem("a", "").SetAttr("x", "", "1").SetAttr("y", "urn:y", "2").SetAttr("x", "", "4")
+	expect.String(e.Bytes()).ToEqual(t, `<a x="4" ns0:y="2" xmlns:ns0="urn:y"/>`)
+
+	expect.Bool(e.HasAttr("y", "urn:y")).ToBeTrue(t)
+	expect.Bool(e.HasAttr("y", "")).ToBeFalse(t)
+	expect.Bool(e.HasAttr("y", "*")).ToBeTrue(t)
+
+	v, found := e.AttrValue("x", "")
+	expect.String(v).ToBe(t, "4")
+	expect.Bool(found).ToBeTrue(t)
+
+	e.RemoveAttr("y", "*")
+	_, found = e.AttrValue("y", "urn:y")
+	expect.Bool(found).ToBeFalse(t)
+	expect.String(e.Bytes()).ToEqual(t, `<a x="4"/>`)
+}
+
+func TestTypedAttrs(t *testing.T) {
+	e := mustParse(t, `<a i=" 42 " b="1" f="-1.5E2" inf="-INF" d="2024-02-29" dt="2024-02-29T10:30:00.5+01:00" bad="yes"/>`)
+
+	i, err := e.AttrInt("i", "")
+	expect.Error(err).ToBeNil(t)
+	expect.Number(i).ToBe(t, 42)
+
+	b, err := e.AttrBool("b", "")
+	expect.Error(err).ToBeNil(t)
+	expect.Bool(b).ToBeTrue(t)
+
+	f, err := e.AttrFloat("f", "")
+	expect.Error(err).ToBeNil(t)
+	expect.Number(f).ToBe(t, -150)
+
+	f, err = e.AttrFloat("inf", "")
+	expect.Error(err).ToBeNil(t)
+	expect.Number(f).ToBeLessThan(t, -1e308)
+
+	d, err := e.AttrTime("d", "")
+	expect.Error(err).ToBeNil(t)
+	expect.Bool(d.Equal(time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC))).ToBeTrue(t)
+
+	dt, err := e.AttrTime("dt", "")
+	expect.Error(err).ToBeNil(t)
+	expect.Bool(dt.Equal(time.Date(2024, 2, 29, 9, 30, 0, 5e8, time.UTC))).ToBeTrue(t)
+
+	_, err = e.AttrBool("bad", "")
+	expect.Error(err).ToContain(t, `attribute bad: invalid xs:boolean "yes"`)
+
+	_, err = e.AttrInt("bad", "")
+	expect.Error(err).ToContain(t, "attribute bad")
+
+	_, err = e.AttrInt("missing", "urn:m")
+	expect.Bool(errors.Is(err, AttrNotFound)).ToBeTrue(t)
+	expect.Error(err).ToContain(t, "{urn:m}missing")
+}
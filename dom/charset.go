@@ -0,0 +1,350 @@
+package dom
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// UnsupportedCharset is returned for a character encoding that is not
+// supported by [CharsetReader] or [Encoder.SetEncoding].
+var UnsupportedCharset = errors.New("unsupported character encoding")
+
+// charset converts between UTF-8 and a character encoding.
+type charset struct {
+	name string // canonical, as written in XML declarations
+	bom  []byte // written at the start of the output
+
+	// decode appends the UTF-8 form of src to dst, returning the number of
+	// bytes of src that were consumed; an incomplete character at the end
+	// is left for the next call.
+	decode func(dst, src []byte) ([]byte, int)
+
+	// encode appends r to dst, returning false if r cannot be represented.
+	encode func(dst []byte, r rune) ([]byte, bool)
+}
+
+var (
+	utf8Charset    = &charset{name: "UTF-8"}
+	utf16Charset   = &charset{name: "UTF-16", bom: []byte{0xFE, 0xFF}, decode: decodeUTF16BE, encode: encodeUTF16BE}
+	utf16BECharset = &charset{name: "UTF-16BE", decode: decodeUTF16BE, encode: encodeUTF16BE}
+	utf16LECharset = &charset{name: "UTF-16LE", decode: decodeUTF16LE, encode: encodeUTF16LE}
+	asciiCharset   = singleByte("US-ASCII", nil, 0x80)
+	latin1Charset  = singleByte("ISO-8859-1", nil, 0x100)
+)
+
+// charsets maps the lower-case names and aliases of the supported encodings.
+var charsets = map[string]*charset{
+	"utf-8":        utf8Charset,
+	"utf8":         utf8Charset,
+	"utf-16":       utf16Charset,
+	"utf16":        utf16Charset,
+	"utf-16be":     utf16BECharset,
+	"utf-16le":     utf16LECharset,
+	"us-ascii":     asciiCharset,
+	"ascii":        asciiCharset,
+	"iso-8859-1":   latin1Charset,
+	"iso8859-1":    latin1Charset,
+	"iso_8859-1":   latin1Charset,
+	"latin1":       latin1Charset,
+	"l1":           latin1Charset,
+	"cp819":        latin1Charset,
+	"iso-8859-15":  latin9Charset,
+	"iso8859-15":   latin9Charset,
+	"iso_8859-15":  latin9Charset,
+	"latin9":       latin9Charset,
+	"windows-1252": windows1252Charset,
+	"cp1252":       windows1252Charset,
+}
+
+var latin9Charset = singleByte("ISO-8859-15", map[byte]rune{
+	0xA4: '€', 0xA6: 'Š', 0xA8: 'š', 0xB4: 'Ž', 0xB8: 'ž', 0xBC: 'Œ', 0xBD: 'œ', 0xBE: 'Ÿ',
+}, 0x100)
+
+// windows1252Charset differs from ISO-8859-1 in 0x80-0x9F; the five bytes
+// that are undefined there are mapped to the control characters, as browsers do.
+var windows1252Charset = singleByte("windows-1252", map[byte]rune{
+	0x80: '€', 0x82: '‚', 0x83: 'ƒ', 0x84: '„', 0x85: '…', 0x86: '†', 0x87: '‡',
+	0x88: 'ˆ', 0x89: '‰', 0x8A: 'Š', 0x8B: '‹', 0x8C: 'Œ', 0x8E: 'Ž',
+	0x91: '‘', 0x92: '’', 0x93: '“', 0x94: '”', 0x95: '•', 0x96: '–', 0x97: '—',
+	0x98: '˜', 0x99: '™', 0x9A: 'š', 0x9B: '›', 0x9C: 'œ', 0x9E: 'ž', 0x9F: 'Ÿ',
+}, 0x100)
+
+func lookupCharset(name string) (*charset, error) {
+	if cs, found := charsets[strings.ToLower(strings.TrimSpace(name))]; found {
+		return cs, nil
+	}
+	return nil, fmt.Errorf("%w: %q", UnsupportedCharset, name)
+}
+
+// singleByte returns a charset in which bytes below size are the same as
+// ISO-8859-1 except for those in diffs; bytes from size upwards are invalid.
+func singleByte(name string, diffs map[byte]rune, size int) *charset {
+	var table [256]rune
+	reverse := make(map[rune]byte)
+	for i := range table {
+		switch r, found := diffs[byte(i)]; {
+		case found:
+			table[i] = r
+		case i < size:
+			table[i] = rune(i)
+		default:
+			table[i] = utf8.RuneError
+			continue
+		}
+		if table[i] >= utf8.RuneSelf {
+			reverse[table[i]] = byte(i)
+		}
+	}
+
+	return &charset{
+		name: name,
+		decode: func(dst, src []byte) ([]byte, int) {
+			for _, b := range src {
+				dst = utf8.AppendRune(dst, table[b])
+			}
+			return dst, len(src)
+		},
+		encode: func(dst []byte, r rune) ([]byte, bool) {
+			if r < utf8.RuneSelf {
+				return append(dst, byte(r)), true
+			}
+			b, found := reverse[r]
+			if !found {
+				return dst, false
+			}
+			return append(dst, b), true
+		},
+	}
+}
+
+func decodeUTF16BE(dst, src []byte) ([]byte, int) {
+	return decodeUTF16(dst, src, func(b []byte) uint16 { return uint16(b[0])<<8 | uint16(b[1]) })
+}
+
+func decodeUTF16LE(dst, src []byte) ([]byte, int) {
+	return decodeUTF16(dst, src, func(b []byte) uint16 { return uint16(b[1])<<8 | uint16(b[0]) })
+}
+
+func decodeUTF16(dst, src []byte, unit func([]byte) uint16) ([]byte, int) {
+	i := 0
+	for ; i+1 < len(src); i += 2 {
+		u := rune(unit(src[i:]))
+		if utf16.IsSurrogate(u) {
+			if i+3 >= len(src) {
+				break // wait for the second half of the pair
+			}
+			if r := utf16.DecodeRune(u, rune(unit(src[i+2:]))); r != utf8.RuneError {
+				dst = utf8.AppendRune(dst, r)
+				i += 2
+				continue
+			}
+			u = utf8.RuneError
+		}
+		dst = utf8.AppendRune(dst, u)
+	}
+	return dst, i
+}
+
+func encodeUTF16BE(dst []byte, r rune) ([]byte, bool) {
+	for _, u := range utf16.AppendRune(nil, r) {
+		dst = append(dst, byte(u>>8), byte(u))
+	}
+	return dst, true
+}
+
+func encodeUTF16LE(dst []byte, r rune) ([]byte, bool) {
+	for _, u := range utf16.AppendRune(nil, r) {
+		dst = append(dst, byte(u), byte(u>>8))
+	}
+	return dst, true
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// CharsetReader converts input in the named character encoding to UTF-8.  It
+// can be used as the CharsetReader of an [xml.Decoder], as it is by [Parse]
+// and the other functions in this package that create their own decoder.
+//
+// The encodings supported are UTF-8, UTF-16 (UTF-16BE, UTF-16LE), US-ASCII,
+// ISO-8859-1 (Latin-1), ISO-8859-15 (Latin-9) and Windows-1252, along with
+// their common aliases.  Names are not case-sensitive.  Otherwise, the error
+// wraps [UnsupportedCharset].
+func CharsetReader(name string, input io.Reader) (io.Reader, error) {
+	cs, err := lookupCharset(name)
+	if err != nil {
+		return nil, err
+	}
+	return newDecodingReader(input, cs), nil
+}
+
+func newDecodingReader(r io.Reader, cs *charset) io.Reader {
+	if cs.decode == nil {
+		return r // UTF-8
+	}
+	return &decodingReader{r: r, cs: cs}
+}
+
+// decodingReader converts input in some encoding to UTF-8.
+type decodingReader struct {
+	r   io.Reader
+	cs  *charset
+	in  []byte // input not yet decoded
+	out []byte // output not yet read
+	buf [4096]byte
+	err error
+}
+
+func (d *decodingReader) Read(p []byte) (int, error) {
+	for len(d.out) == 0 {
+		if d.err != nil {
+			if len(d.in) > 0 {
+				// an incomplete character at the end of the input
+				d.out, d.in = utf8.AppendRune(d.out[:0], utf8.RuneError), nil
+				break
+			}
+			return 0, d.err
+		}
+
+		n, err := d.r.Read(d.buf[len(d.in):])
+		d.in = d.buf[:len(d.in)+n]
+		d.err = err
+
+		var used int
+		d.out, used = d.cs.decode(d.out[:0], d.in)
+		d.in = d.buf[:copy(d.buf[:], d.in[used:])]
+	}
+
+	n := copy(p, d.out)
+	d.out = d.out[n:]
+	return n, nil
+}
+
+// sniffCharset works out the encoding of the input from its byte order mark
+// or its XML declaration, as described in appendix F of the XML
+// specification.  The input is converted to UTF-8, without the byte order
+// mark.  The charset is nil if the encoding was not determined.
+func sniffCharset(r io.Reader) (io.Reader, *charset) {
+	br := bufio.NewReader(r)
+	head := peekWhile(br, 4, func(head []byte) bool {
+		return slices.ContainsFunc(byteOrderMarks, func(bom []byte) bool {
+			return len(bom) > len(head) && bytes.HasPrefix(bom, head)
+		})
+	})
+	switch {
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		_, _ = br.Discard(3)
+		return br, utf8Charset
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		_, _ = br.Discard(2)
+		return newDecodingReader(br, utf16BECharset), utf16Charset
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		_, _ = br.Discard(2)
+		return newDecodingReader(br, utf16LECharset), utf16Charset
+	case bytes.Equal(head, []byte{0, '<', 0, '?'}):
+		return newDecodingReader(br, utf16BECharset), utf16Charset
+	case bytes.Equal(head, []byte{'<', 0, '?', 0}):
+		return newDecodingReader(br, utf16LECharset), utf16Charset
+	}
+
+	head = peekWhile(br, 512, func(head []byte) bool {
+		n := min(len(head), len(xmlDecl))
+		return bytes.Equal(head[:n], xmlDecl[:n]) && !bytes.Contains(head, []byte("?>"))
+	})
+	if !bytes.HasPrefix(head, xmlDecl) {
+		return br, nil
+	}
+	end := bytes.Index(head, []byte("?>"))
+	if end < 0 {
+		return br, nil
+	}
+	encoding := procInstParam(head[5:end], "encoding")
+	cs, err := lookupCharset(encoding)
+	switch {
+	case encoding == "" || err != nil:
+		return br, nil // left for the decoder to report
+	case cs.name == "UTF-16" || cs.name == "UTF-16BE" || cs.name == "UTF-16LE":
+		return br, utf8Charset // the declaration contradicts the bytes read so far
+	}
+	return newDecodingReader(br, cs), cs
+}
+
+// byteOrderMarks are the byte order marks, and the start of an XML
+// declaration in UTF-16 without one, recognised by sniffCharset.
+var byteOrderMarks = [][]byte{
+	{0xEF, 0xBB, 0xBF},
+	{0xFE, 0xFF},
+	{0xFF, 0xFE},
+	{0, '<', 0, '?'},
+	{'<', 0, '?', 0},
+}
+
+var xmlDecl = []byte("<?xml")
+
+// peekWhile returns the start of the input without consuming it.  It has at
+// least what is already buffered, and more is read only while more returns
+// true for it, up to limit bytes, so that a short document on a connection
+// that is kept open can be parsed without waiting for more input.
+func peekWhile(br *bufio.Reader, limit int, more func(head []byte) bool) []byte {
+	head, err := br.Peek(max(1, min(br.Buffered(), limit)))
+	for err == nil && len(head) < limit && more(head) {
+		head, err = br.Peek(max(len(head)+1, min(br.Buffered(), limit)))
+	}
+	return head
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// encodingWriter converts UTF-8 to some encoding.  Characters that cannot be
+// represented are written as character references.
+type encodingWriter struct {
+	w       io.Writer
+	cs      *charset
+	partial []byte // an incomplete UTF-8 sequence at the end of the last write
+	buf     []byte
+	started bool
+}
+
+func (w *encodingWriter) Write(p []byte) (int, error) {
+	out := w.buf[:0]
+	if !w.started {
+		out = append(out, w.cs.bom...)
+		w.started = true
+	}
+
+	src := p
+	if len(w.partial) > 0 {
+		src = append(w.partial, p...)
+		w.partial = nil
+	}
+
+	for len(src) > 0 {
+		if !utf8.FullRune(src) {
+			w.partial = bytes.Clone(src)
+			break
+		}
+		r, size := utf8.DecodeRune(src)
+		src = src[size:]
+
+		var ok bool
+		if out, ok = w.cs.encode(out, r); !ok {
+			ref := strconv.AppendInt([]byte("&#"), int64(r), 10)
+			for _, c := range append(ref, ';') {
+				out, _ = w.cs.encode(out, rune(c))
+			}
+		}
+	}
+
+	w.buf = out
+	if _, err := w.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
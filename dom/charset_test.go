@@ -0,0 +1,135 @@
+package dom
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/rickb777/expect"
+)
+
+func utf16Bytes(s string, bigEndian, bom bool) []byte {
+	var b []byte
+	units := utf16.Encode([]rune(s))
+	if bom {
+		units = append([]uint16{0xFEFF}, units...)
+	}
+	for _, u := range units {
+		if bigEndian {
+			b = append(b, byte(u>>8), byte(u))
+		} else {
+			b = append(b, byte(u), byte(u>>8))
+		}
+	}
+	return b
+}
+
+func TestParseCharsets(t *testing.T) {
+	const want = "café € “quoted” 𝄞"
+
+	cases := map[string][]byte{
+		"latin1":  []byte("<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><a>caf\xe9</a>"),
+		"latin9":  []byte("<?xml version='1.0' encoding='iso-8859-15'?><a>caf\xe9 \xa4</a>"),
+		"cp1252":  []byte("<?xml version=\"1.0\" encoding=\"windows-1252\"?><a>caf\xe9 \x80 \x93quoted\x94</a>"),
+		"utf8bom": []byte("\xef\xbb\xbf<?xml version=\"1.0\"?><a>" + want + "</a>"),
+		"utf16le": utf16Bytes(`<?xml version="1.0" encoding="UTF-16"?><a>`+want+`</a>`, false, true),
+		"utf16be": utf16Bytes(`<?xml version="1.0" encoding="UTF-16"?><a>`+want+`</a>`, true, true),
+		"nobom":   utf16Bytes(`<?xml version="1.0" encoding="UTF-16BE"?><a>`+want+`</a>`, true, false),
+	}
+
+	for name, input := range cases {
+		for _, opts := range []ParseOptions{{}, {Preserve: true}} {
+			doc, err := opts.Parse(bytes.NewReader(input))
+			expect.Error(err).I(name).ToBeNil(t)
+			expect.Bool(strings.HasPrefix(want, string(doc.Root().Text()))).I(name).ToBeTrue(t)
+		}
+	}
+
+	_, err := ParseString(`<?xml version="1.0" encoding="EBCDIC"?><a/>`)
+	expect.Bool(errors.Is(err, UnsupportedCharset)).ToBeTrue(t)
+}
+
+func TestParseDoesNotWaitForInput(t *testing.T) {
+	match, _ := MatchPath("//b")
+	for _, input := range []string{`<?xml version="1.0" encoding="ISO-8859-1"?><a><b/>`, `<?xml version="1.0"?><a><b/>`, `<a><b/>`} {
+		r, w := io.Pipe()
+		go func() { _, _ = w.Write([]byte(input)) }() // the connection stays open
+
+		found := make(chan string)
+		go func() {
+			_ = Stream(r, match, func(e *Element) error {
+				found <- e.Name.Local
+				return StopStream
+			})
+		}()
+
+		select {
+		case name := <-found:
+			expect.String(name).I(input).ToBe(t, "b")
+		case <-time.After(5 * time.Second):
+			t.Fatalf("%s: waited for more input", input)
+		}
+		_ = w.Close()
+	}
+}
+
+func TestCharsetReader(t *testing.T) {
+	r, err := CharsetReader("Latin1", strings.NewReader("\xe0 la carte"))
+	expect.Error(err).ToBeNil(t)
+	b, err := io.ReadAll(r)
+	expect.Error(err).ToBeNil(t)
+	expect.String(b).ToEqual(t, "à la carte")
+
+	// an odd number of bytes, and a surrogate pair split across reads
+	r, _ = CharsetReader("UTF-16LE", io.MultiReader(
+		bytes.NewReader([]byte{'a', 0, 0x34, 0xD8}),
+		bytes.NewReader([]byte{0x1E, 0xDD, 'b'})))
+	b, err = io.ReadAll(r)
+	expect.Error(err).ToBeNil(t)
+	expect.String(b).ToEqual(t, "a𝄞�")
+
+	_, err = CharsetReader("KOI8-R", strings.NewReader(""))
+	expect.Bool(errors.Is(err, UnsupportedCharset)).ToBeTrue(t)
+}
+
+func TestEncodeCharsets(t *testing.T) {
+	doc := CreateDocument()
+	doc.SetRoot(Elem("a", "").SetAttr("x", "", "€").SetText("café € ☃"))
+
+	encode := func(charset string) []byte {
+		var buf bytes.Buffer
+		enc := NewEncoder(&buf)
+		expect.Error(enc.SetEncoding(charset)).ToBeNil(t)
+		expect.Error(doc.Encode(enc)).ToBeNil(t)
+		return buf.Bytes()
+	}
+
+	expect.String(encode("ISO-8859-1")).ToEqual(t,
+		"<?xml version=\"1.0\" encoding=\"ISO-8859-1\"?><a x=\"&#8364;\">caf\xe9 &#8364; &#9731;</a>")
+	expect.String(encode("cp1252")).ToEqual(t,
+		"<?xml version=\"1.0\" encoding=\"windows-1252\"?><a x=\"\x80\">caf\xe9 \x80 &#9731;</a>")
+	expect.String(encode("US-ASCII")).ToEqual(t,
+		`<?xml version="1.0" encoding="US-ASCII"?><a x="&#8364;">caf&#233; &#8364; &#9731;</a>`)
+	expect.String(encode("utf-8")).ToEqual(t,
+		`<?xml version="1.0" encoding="UTF-8"?><a x="€">café € ☃</a>`)
+
+	utf16 := encode("UTF-16")
+	expect.String(utf16).ToEqual(t,
+		string(utf16Bytes(`<?xml version="1.0" encoding="UTF-16"?><a x="€">café € ☃</a>`, true, true)))
+
+	// round trip
+	for _, charset := range []string{"ISO-8859-1", "windows-1252", "ISO-8859-15", "US-ASCII", "UTF-16", "UTF-16LE"} {
+		parsed, err := Parse(bytes.NewReader(encode(charset)))
+		expect.Error(err).I(charset).ToBeNil(t)
+		expect.String(parsed.Encoding).ToBe(t, charset)
+		expect.String(parsed.Root().Text()).I(charset).ToEqual(t, "café € ☃")
+		expect.String(parsed.Root().Attributes[0].Value).I(charset).ToBe(t, "€")
+	}
+
+	err := NewEncoder(io.Discard).SetEncoding("EBCDIC")
+	expect.Bool(errors.Is(err, UnsupportedCharset)).ToBeTrue(t)
+}
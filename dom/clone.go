@@ -0,0 +1,100 @@
+package dom
+
+import (
+	"bytes"
+	"slices"
+)
+
+// Clone returns a deep copy of node.  The copy has no parent, but has the
+// same owner document.  Its Content, Attributes and descendants are
+// independent of the original, so either can be altered without affecting
+// the other.
+func (node *Element) Clone() *Element {
+	res := node.copyTree()
+	res.setOwner(node.doc)
+	return res
+}
+
+// copyTree is like Clone, except that the copy has no owner document.  It is
+// built detached so that adding the children does not count as a change to
+// the owner document, which would invalidate its ID index.
+func (node *Element) copyTree() *Element {
+	res := node.ShallowClone()
+	res.doc = nil
+	res.Content = bytes.Clone(node.Content)
+
+	res.children = make([]*Element, 0, len(node.children))
+	if node.nodes == nil {
+		for _, c := range node.children {
+			res.AddChild(c.copyTree())
+		}
+		return res
+	}
+
+	res.nodes = make([]Node, 0, len(node.nodes))
+	for _, n := range node.nodes {
+		if c, ok := n.(*Element); ok {
+			res.AddChild(c.copyTree())
+		} else {
+			res.AddNode(n.clone())
+		}
+	}
+	return res
+}
+
+// ShallowClone returns a copy of node that has the same name, attributes and
+// owner document, but no parent, no children and no content.
+func (node *Element) ShallowClone() *Element {
+	res := CreateElement(node.Name)
+	res.Attributes = append(res.Attributes, node.Attributes...)
+	res.src = node.src
+	res.pos = node.pos
+	res.doc = node.doc
+	return res
+}
+
+// Clone returns a deep copy of the document, including its declaration,
+// Prolog and Epilog.
+func (doc *Document) Clone() *Document {
+	res := &Document{
+		Version:    doc.Version,
+		Encoding:   doc.Encoding,
+		Standalone: doc.Standalone,
+		Prolog:     cloneNodes(doc.Prolog),
+		Epilog:     cloneNodes(doc.Epilog),
+		decl:       doc.decl,
+		ids:        idIndex{attrs: doc.ids.attrs},
+	}
+	if doc.root != nil {
+		res.SetRoot(doc.root.copyTree())
+	}
+	return res
+}
+
+func cloneNodes(nodes []Node) []Node {
+	if nodes == nil {
+		return nil
+	}
+	res := make([]Node, len(nodes))
+	for i, n := range nodes {
+		res[i] = n.clone()
+	}
+	return res
+}
+
+func (node *Element) clone() Node { return node.Clone() }
+
+func (t Text) clone() Node { return Text(bytes.Clone(t)) }
+
+func (c CData) clone() Node { return CData(bytes.Clone(c)) }
+
+func (c Comment) clone() Node { return Comment(bytes.Clone(c)) }
+
+func (pi ProcInst) clone() Node {
+	return ProcInst{Target: pi.Target, Inst: slices.Clone(pi.Inst)}
+}
+
+func (dt *DocType) clone() Node {
+	res := *dt
+	return &res
+}
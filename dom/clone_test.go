@@ -0,0 +1,58 @@
+package dom
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestElementClone(t *testing.T) {
+	doc := parseDoc()
+	root := doc.Root()
+	node2 := root.ChildAt(1)
+
+	c := node2.Clone()
+	expect.Any(c.Parent()).ToBeNil(t)
+	expect.String(c.String()).ToBe(t, node2.String())
+	expect.Bool(c.FirstChild().Parent() == c).ToBeTrue(t)
+
+	c.Content[0] = 'X'
+	c.Attributes[0].Value = "changed"
+	c.FirstChild().AddChild(Elem("extra", ""))
+	expect.String(node2.Content).ToEqual(t, "I am Node 2")
+	expect.String(node2.Attributes[0].Value).ToBe(t, "0")
+	expect.Number(node2.FirstChild().ChildCount()).ToBe(t, 0)
+	expect.Bool(node2.Parent() == root).ToBeTrue(t)
+}
+
+func TestShallowClone(t *testing.T) {
+	node2 := parseDoc().Root().ChildAt(1)
+
+	c := node2.ShallowClone()
+	expect.Number(c.ChildCount()).ToBe(t, 0)
+	expect.Any(c.Content).ToBeNil(t)
+	expect.Slice(c.Attributes).ToBe(t, node2.Attributes...)
+	c.Attributes[0].Value = "changed"
+	expect.String(node2.Attributes[0].Value).ToBe(t, "0")
+}
+
+func TestDocumentClone(t *testing.T) {
+	const input = `<?xml version="1.0"?>
+<!DOCTYPE p>
+<!-- c -->
+<p>Hello <b>big</b> world</p>
+`
+	doc, err := ParseOptions{Preserve: true}.Parse(strings.NewReader(input))
+	expect.Error(err).ToBeNil(t)
+
+	c := doc.Clone()
+	var sb strings.Builder
+	expect.Error(c.Encode(NewEncoder(&sb).SetPreserve(true))).ToBeNil(t)
+	expect.String(sb.String()).ToBe(t, input)
+
+	c.DocType().Name = "q"
+	c.Root().FirstChild().AddText("!")
+	expect.String(doc.DocType().Name).ToBe(t, "p")
+	expect.String(doc.Root().String()).ToBe(t, "<p>Hello <b>big</b> world</p>\n")
+}
@@ -0,0 +1,108 @@
+package dom
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The typed content accessors interpret the text of an element, as given by
+// [Element.Text], using the lexical forms of the XML Schema simple types.
+// Surrounding whitespace is ignored.  The setters replace the text using the
+// canonical lexical form; see [Element.SetText].
+
+// ContentString returns the text of node as a string.
+func (node *Element) ContentString() string {
+	return string(node.Text())
+}
+
+// ContentInt returns the text of node as an int, e.g. from an xs:int.
+func (node *Element) ContentInt() (int, error) {
+	return contentAs(node, parseInt)
+}
+
+// ContentFloat returns the text of node as a float64, e.g. from an xs:double,
+// which may also be "INF", "-INF" or "NaN".
+func (node *Element) ContentFloat() (float64, error) {
+	return contentAs(node, parseFloat)
+}
+
+// ContentBool returns the text of node as a bool.  As for xs:boolean, the text
+// must be "true", "false", "1" or "0".
+func (node *Element) ContentBool() (bool, error) {
+	return contentAs(node, parseBool)
+}
+
+// ContentTime returns the text of node as a time, from an xs:dateTime or
+// xs:date.  If there is no timezone, UTC is assumed.
+func (node *Element) ContentTime() (time.Time, error) {
+	return contentAs(node, parseTime)
+}
+
+// ContentDuration returns the text of node as a duration, from an xs:duration
+// such as "P1DT2H30M".  Durations with years or months are rejected because
+// their length is not fixed.
+func (node *Element) ContentDuration() (time.Duration, error) {
+	return contentAs(node, parseDuration)
+}
+
+// ContentBase64 returns the text of node decoded from xs:base64Binary.
+// Whitespace within the text is ignored.
+func (node *Element) ContentBase64() ([]byte, error) {
+	return contentAs(node, func(s string) ([]byte, error) {
+		return base64.StdEncoding.DecodeString(strings.Join(strings.Fields(s), ""))
+	})
+}
+
+func contentAs[T any](node *Element, parse func(string) (T, error)) (T, error) {
+	v, err := parse(string(node.Text()))
+	if err != nil {
+		return v, fmt.Errorf("%s: content: %w", node.Location(), err)
+	}
+	return v, nil
+}
+
+// SetContentString sets the text of node.
+// The altered node is returned.
+func (node *Element) SetContentString(s string) *Element {
+	return node.SetText(s)
+}
+
+// SetContentInt sets the text of node to an xs:int.
+// The altered node is returned.
+func (node *Element) SetContentInt(i int) *Element {
+	return node.SetText(strconv.Itoa(i))
+}
+
+// SetContentFloat sets the text of node to an xs:double, e.g. "1.5E20" or "INF".
+// The altered node is returned.
+func (node *Element) SetContentFloat(f float64) *Element {
+	return node.SetText(formatFloat(f))
+}
+
+// SetContentBool sets the text of node to an xs:boolean, i.e. "true" or "false".
+// The altered node is returned.
+func (node *Element) SetContentBool(b bool) *Element {
+	return node.SetText(strconv.FormatBool(b))
+}
+
+// SetContentTime sets the text of node to an xs:dateTime in UTC, e.g.
+// "2024-02-29T09:30:00.5Z".
+// The altered node is returned.
+func (node *Element) SetContentTime(t time.Time) *Element {
+	return node.SetText(formatTime(t))
+}
+
+// SetContentDuration sets the text of node to an xs:duration, e.g. "P1DT2H30M".
+// The altered node is returned.
+func (node *Element) SetContentDuration(d time.Duration) *Element {
+	return node.SetText(formatDuration(d))
+}
+
+// SetContentBase64 sets the text of node to the xs:base64Binary encoding of data.
+// The altered node is returned.
+func (node *Element) SetContentBase64(data []byte) *Element {
+	return node.SetText(base64.StdEncoding.EncodeToString(data))
+}
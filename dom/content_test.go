@@ -0,0 +1,92 @@
+package dom
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rickb777/expect"
+)
+
+func TestContentAccessors(t *testing.T) {
+	e := mustParse(t, `<v>
+  <i> -7 </i><f>2.5e-1</f><b>false</b><t>2024-02-29T10:30:00Z</t>
+  <d>-P1DT2H0.25S</d><x>aGVs
+  bG8=</x><bad>P1Y</bad>
+</v>`)
+	c := e.Children()
+
+	i, err := c[0].ContentInt()
+	expect.Error(err).ToBeNil(t)
+	expect.Number(i).ToBe(t, -7)
+
+	f, err := c[1].ContentFloat()
+	expect.Error(err).ToBeNil(t)
+	expect.Number(f).ToBe(t, 0.25)
+
+	b, err := c[2].ContentBool()
+	expect.Error(err).ToBeNil(t)
+	expect.Bool(b).ToBeFalse(t)
+
+	tm, err := c[3].ContentTime()
+	expect.Error(err).ToBeNil(t)
+	expect.Bool(tm.Equal(time.Date(2024, 2, 29, 10, 30, 0, 0, time.UTC))).ToBeTrue(t)
+
+	d, err := c[4].ContentDuration()
+	expect.Error(err).ToBeNil(t)
+	expect.Number(d).ToBe(t, -(26*time.Hour + 250*time.Millisecond))
+
+	x, err := c[5].ContentBase64()
+	expect.Error(err).ToBeNil(t)
+	expect.String(x).ToEqual(t, "hello")
+
+	_, err = c[6].ContentDuration()
+	expect.Error(err).ToContain(t, "/v[1]/bad[1]: content: ")
+
+	_, err = c[6].ContentInt()
+	expect.Error(err).ToContain(t, "/v[1]/bad[1]: content: ")
+
+	_, err = Elem("x", "").SetContentString("yes").ContentBool()
+	expect.Error(err).ToContain(t, `invalid xs:boolean "yes"`)
+}
+
+func TestContentSetters(t *testing.T) {
+	e := Elem("x", "")
+	cases := []struct {
+		set  func()
+		want string
+	}{
+		{func() { e.SetContentString("a<b") }, "a<b"},
+		{func() { e.SetContentInt(-42) }, "-42"},
+		{func() { e.SetContentFloat(1.5e20) }, "1.5E20"},
+		{func() { e.SetContentFloat(math.Inf(-1)) }, "-INF"},
+		{func() { e.SetContentFloat(math.NaN()) }, "NaN"},
+		{func() { e.SetContentBool(true) }, "true"},
+		{func() { e.SetContentTime(time.Date(2024, 2, 29, 10, 30, 0, 5e8, time.FixedZone("", 3600))) }, "2024-02-29T09:30:00.5Z"},
+		{func() { e.SetContentDuration(0) }, "PT0S"},
+		{func() { e.SetContentDuration(48 * time.Hour) }, "P2D"},
+		{func() { e.SetContentDuration(-(26*time.Hour + 90*time.Second + time.Nanosecond)) }, "-P1DT2H1M30.000000001S"},
+		{func() { e.SetContentBase64([]byte("hello")) }, "aGVsbG8="},
+	}
+
+	for _, c := range cases {
+		c.set()
+		expect.String(e.ContentString()).ToBe(t, c.want)
+	}
+}
+
+func TestContentRoundTrip(t *testing.T) {
+	e := Elem("x", "")
+
+	for _, d := range []time.Duration{0, time.Nanosecond, -90 * time.Minute, 1000 * time.Hour} {
+		got, err := e.SetContentDuration(d).ContentDuration()
+		expect.Error(err).ToBeNil(t)
+		expect.Number(got).ToBe(t, d)
+	}
+
+	for _, f := range []float64{0, -1.25, 6.02214076e23, math.Inf(1)} {
+		got, err := e.SetContentFloat(f).ContentFloat()
+		expect.Error(err).ToBeNil(t)
+		expect.Number(got).ToBe(t, f)
+	}
+}
@@ -0,0 +1,446 @@
+package dom
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// EditKind identifies the kind of change described by an [Edit].
+type EditKind int
+
+const (
+	InsertElement EditKind = iota + 1
+	DeleteElement
+	MoveElement
+	ChangeAttr
+	ChangeContent
+)
+
+func (k EditKind) String() string {
+	switch k {
+	case InsertElement:
+		return "insert"
+	case DeleteElement:
+		return "delete"
+	case MoveElement:
+		return "move"
+	case ChangeAttr:
+		return "attr"
+	case ChangeContent:
+		return "content"
+	}
+	return fmt.Sprintf("EditKind(%d)", int(k))
+}
+
+// Edit is one change in the [EditScript] returned by [Diff].
+type Edit struct {
+	Kind EditKind
+	// OldPath locates the element in the old tree; it is empty for InsertElement.
+	OldPath string
+	// NewPath locates the element in the new tree; it is empty for DeleteElement.
+	NewPath string
+	// Old and New are the elements concerned in the old and new trees.
+	Old, New *Element
+	// OldAttr and NewAttr are the attribute before and after a ChangeAttr;
+	// one of them is nil if the attribute was added or removed.
+	OldAttr, NewAttr *xml.Attr
+}
+
+// EditScript is the list of changes that turn one element tree into another.
+type EditScript []Edit
+
+// Diff compares two element trees and returns the changes that turn old into
+// new.  As with [Equal], names are compared using their namespace URIs and
+// namespace declarations are not treated as attributes; comments and
+// processing instructions are ignored.
+//
+// Children are matched in order: identical subtrees are matched first, then
+// elements with the same name.  Elements that are deleted in one place and
+// inserted unchanged in another are reported as moved.
+func Diff(old, new *Element) EditScript {
+	d := &differ{
+		hashes:  make(map[*Element]uint64),
+		classes: make(map[uint64][]*Element),
+		class:   make(map[*Element]*Element),
+	}
+	if old.Name != new.Name {
+		d.deleted(old)
+		d.inserted(new)
+	} else {
+		d.diff(old, new)
+	}
+	return d.findMoves()
+}
+
+type differ struct {
+	script  EditScript
+	hashes  map[*Element]uint64
+	classes map[uint64][]*Element // the distinct subtrees with each hash
+	class   map[*Element]*Element // see classOf
+}
+
+func (d *differ) deleted(e *Element) {
+	d.script = append(d.script, Edit{Kind: DeleteElement, OldPath: e.Path(), Old: e})
+}
+
+func (d *differ) inserted(e *Element) {
+	d.script = append(d.script, Edit{Kind: InsertElement, NewPath: e.Path(), New: e})
+}
+
+func (d *differ) changed(kind EditKind, old, new *Element, oldAttr, newAttr *xml.Attr) {
+	d.script = append(d.script, Edit{
+		Kind:    kind,
+		OldPath: old.Path(),
+		NewPath: new.Path(),
+		Old:     old,
+		New:     new,
+		OldAttr: oldAttr,
+		NewAttr: newAttr,
+	})
+}
+
+// diff compares two elements that have the same name.
+func (d *differ) diff(old, new *Element) {
+	if d.same(old, new) {
+		return
+	}
+
+	for i, a := range old.Attributes {
+		if isNamespaceDecl(a) {
+			continue
+		}
+		if j := attrIndex(new.Attributes, a.Name); j < 0 {
+			d.changed(ChangeAttr, old, new, &old.Attributes[i], nil)
+		} else if new.Attributes[j].Value != a.Value {
+			d.changed(ChangeAttr, old, new, &old.Attributes[i], &new.Attributes[j])
+		}
+	}
+	for j, a := range new.Attributes {
+		if !isNamespaceDecl(a) && attrIndex(old.Attributes, a.Name) < 0 {
+			d.changed(ChangeAttr, old, new, nil, &new.Attributes[j])
+		}
+	}
+
+	// text that has moved past a child is a change too, unless children
+	// were added or removed
+	ro, rn := old.textRuns(), new.textRuns()
+	if !bytes.Equal(old.Text(), new.Text()) || (len(ro) == len(rn) && !slices.EqualFunc(ro, rn, bytes.Equal)) {
+		d.changed(ChangeContent, old, new, nil, nil)
+	}
+
+	d.diffChildren(old.children, new.children)
+}
+
+func (d *differ) diffChildren(old, new []*Element) {
+	// identical subtrees are the anchors; the gaps between them are matched by name
+	anchors := d.anchors(old, new)
+	i, j := 0, 0
+	for _, anchor := range append(anchors, [2]int{len(old), len(new)}) {
+		d.diffGap(old[i:anchor[0]], new[j:anchor[1]])
+		i, j = anchor[0]+1, anchor[1]+1
+	}
+}
+
+func (d *differ) diffGap(old, new []*Element) {
+	pairs := lcs(old, new, func(a, b *Element) bool { return a.Name == b.Name })
+	i, j := 0, 0
+	for _, pair := range append(pairs, [2]int{len(old), len(new)}) {
+		for ; i < pair[0]; i++ {
+			d.deleted(old[i])
+		}
+		for ; j < pair[1]; j++ {
+			d.inserted(new[j])
+		}
+		if i < len(old) && j < len(new) {
+			d.diff(old[i], new[j])
+		}
+		i, j = pair[0]+1, pair[1]+1
+	}
+}
+
+// anchors finds the identical subtrees in two lists of children, returning
+// the pairs of positions in order.  As in patience diff, subtrees that occur
+// once in each list are paired first, keeping the longest run of pairs that
+// are in the same order; then the rest are matched by LCS between them.  So
+// long lists with few changes are compared quickly.
+func (d *differ) anchors(old, new []*Element) [][2]int {
+	type occurrence struct{ count, index int }
+	inOld := make(map[uint64]occurrence, len(old))
+	for i, e := range old {
+		o := inOld[d.hash(e)]
+		inOld[d.hash(e)] = occurrence{count: o.count + 1, index: i}
+	}
+	inNew := make(map[uint64]int, len(new))
+	for _, e := range new {
+		inNew[d.hash(e)]++
+	}
+
+	var unique [][2]int
+	for j, e := range new {
+		h := d.hash(e)
+		if o := inOld[h]; o.count == 1 && inNew[h] == 1 && d.same(old[o.index], e) {
+			unique = append(unique, [2]int{o.index, j})
+		}
+	}
+
+	var res [][2]int
+	i, j := 0, 0
+	for _, pair := range append(longestIncreasing(unique), [2]int{len(old), len(new)}) {
+		for _, p := range lcs(old[i:pair[0]], new[j:pair[1]], d.same) {
+			res = append(res, [2]int{i + p[0], j + p[1]})
+		}
+		if pair[0] < len(old) {
+			res = append(res, pair)
+		}
+		i, j = pair[0]+1, pair[1]+1
+	}
+	return res
+}
+
+// longestIncreasing returns the longest subsequence of pairs, which are in
+// order of their second positions, that is also in order of their first
+// positions.
+func longestIncreasing(pairs [][2]int) [][2]int {
+	// tails[k] is the index of the pair ending the best subsequence of length k+1
+	var tails []int
+	prev := make([]int, len(pairs))
+	for k, p := range pairs {
+		n := sort.Search(len(tails), func(t int) bool { return pairs[tails[t]][0] >= p[0] })
+		prev[k] = -1
+		if n > 0 {
+			prev[k] = tails[n-1]
+		}
+		if n == len(tails) {
+			tails = append(tails, k)
+		} else {
+			tails[n] = k
+		}
+	}
+
+	res := make([][2]int, len(tails))
+	if len(tails) > 0 {
+		for k, n := tails[len(tails)-1], len(tails)-1; n >= 0; n-- {
+			res[n] = pairs[k]
+			k = prev[k]
+		}
+	}
+	return res
+}
+
+// findMoves replaces each deletion that has a matching insertion by a move.
+func (d *differ) findMoves() EditScript {
+	consumed := make([]bool, len(d.script))
+	res := make(EditScript, 0, len(d.script))
+	for i, edit := range d.script {
+		if consumed[i] {
+			continue
+		}
+		if edit.Kind == DeleteElement {
+			for j, other := range d.script {
+				if other.Kind == InsertElement && !consumed[j] && d.same(edit.Old, other.New) {
+					consumed[j] = true
+					edit.Kind = MoveElement
+					edit.NewPath = other.NewPath
+					edit.New = other.New
+					break
+				}
+			}
+		}
+		res = append(res, edit)
+	}
+	return res
+}
+
+// same returns true if two subtrees are equal according to [Equal].
+func (d *differ) same(a, b *Element) bool {
+	return d.hash(a) == d.hash(b) && d.classOf(a) == d.classOf(b)
+}
+
+// classOf returns the first element found that is equal to e, possibly e
+// itself.  The hash narrows the search, and [Equal] confirms the match, in
+// case the hashes collide.  Each element is compared at most once.
+func (d *differ) classOf(e *Element) *Element {
+	if c, found := d.class[e]; found {
+		return c
+	}
+	h := d.hash(e)
+	c := e
+	for _, rep := range d.classes[h] {
+		if eq, _ := Equal(e, rep); eq {
+			c = rep
+			break
+		}
+	}
+	if c == e {
+		d.classes[h] = append(d.classes[h], e)
+	}
+	d.class[e] = c
+	return c
+}
+
+// hash summarises a subtree; subtrees that are equal according to [Equal] have the same hash.
+func (d *differ) hash(e *Element) uint64 {
+	if h, found := d.hashes[e]; found {
+		return h
+	}
+	h := fnv.New64a()
+	_, _ = fmt.Fprintf(h, "%s\x00%s\x00", e.Name.Space, e.Name.Local)
+	for _, a := range e.Attributes {
+		if !isNamespaceDecl(a) {
+			_, _ = fmt.Fprintf(h, "%s\x00%s\x00%s\x00", a.Name.Space, a.Name.Local, a.Value)
+		}
+	}
+	runs := e.textRuns()
+	for i, c := range e.children {
+		_, _ = fmt.Fprintf(h, "%d\x00%s\x00%x\x00", len(runs[i]), runs[i], d.hash(c))
+	}
+	_, _ = fmt.Fprintf(h, "%d\x00%s", len(runs[len(runs)-1]), runs[len(runs)-1])
+	sum := h.Sum64()
+	d.hashes[e] = sum
+	return sum
+}
+
+func attrIndex(attrs []xml.Attr, name xml.Name) int {
+	for i, a := range attrs {
+		if a.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// lcs finds the longest common subsequence of two lists, returning the
+// pairs of matching positions in order.  It needs space linear in the length
+// of the lists.
+func lcs(a, b []*Element, match func(a, b *Element) bool) [][2]int {
+	// common prefixes and suffixes are matched directly
+	start := 0
+	for start < len(a) && start < len(b) && match(a[start], b[start]) {
+		start++
+	}
+	endA, endB := len(a), len(b)
+	for endA > start && endB > start && match(a[endA-1], b[endB-1]) {
+		endA--
+		endB--
+	}
+
+	res := make([][2]int, 0, start+len(a)-endA)
+	for i := 0; i < start; i++ {
+		res = append(res, [2]int{i, i})
+	}
+
+	res = hirschberg(a[start:endA], b[start:endB], start, start, match, res)
+
+	for i := 0; endA+i < len(a); i++ {
+		res = append(res, [2]int{endA + i, endB + i})
+	}
+	return res
+}
+
+// hirschberg appends the pairs of the longest common subsequence of a and b
+// to res, using Hirschberg's divide and conquer algorithm.  The positions are
+// offset by i and j.
+func hirschberg(a, b []*Element, i, j int, match func(a, b *Element) bool, res [][2]int) [][2]int {
+	switch {
+	case len(a) == 0 || len(b) == 0:
+		return res
+	case len(a) == 1:
+		for k := range b {
+			if match(a[0], b[k]) {
+				return append(res, [2]int{i, j + k})
+			}
+		}
+		return res
+	}
+
+	// split b where the LCS lengths of the two halves of a add up to the most
+	mid := len(a) / 2
+	forward := lcsLengths(len(a[:mid]), len(b), func(x, y int) bool { return match(a[x], b[y]) })
+	backward := lcsLengths(len(a[mid:]), len(b), func(x, y int) bool { return match(a[len(a)-1-x], b[len(b)-1-y]) })
+	split := 0
+	for k := range forward {
+		if forward[k]+backward[len(b)-k] > forward[split]+backward[len(b)-split] {
+			split = k
+		}
+	}
+
+	res = hirschberg(a[:mid], b[:split], i, j, match, res)
+	return hirschberg(a[mid:], b[split:], i+mid, j+split, match, res)
+}
+
+// lcsLengths returns the length of the LCS of n items with each prefix of m
+// items, where match compares item x of the first with item y of the second.
+func lcsLengths(n, m int, match func(x, y int) bool) []int {
+	prev, cur := make([]int, m+1), make([]int, m+1)
+	for x := 0; x < n; x++ {
+		for y := 0; y < m; y++ {
+			if match(x, y) {
+				cur[y+1] = prev[y] + 1
+			} else {
+				cur[y+1] = max(prev[y+1], cur[y])
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return prev
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// Unified renders the edit script in the style of a unified diff.  Each edit
+// has a header giving its location, followed by the removed lines prefixed
+// with "-" and the added lines prefixed with "+".
+func (s EditScript) Unified() string {
+	var b strings.Builder
+	_ = s.WriteUnified(&b)
+	return b.String()
+}
+
+// WriteUnified writes the edit script to w in the same form as [EditScript.Unified].
+func (s EditScript) WriteUnified(w io.Writer) error {
+	if len(s) == 0 {
+		return nil
+	}
+	var b bytes.Buffer
+	b.WriteString("--- old\n+++ new\n")
+	for _, edit := range s {
+		switch edit.Kind {
+		case InsertElement:
+			fmt.Fprintf(&b, "@@ %s @@\n", edit.NewPath)
+			writeLines(&b, "+", edit.New.String())
+		case DeleteElement:
+			fmt.Fprintf(&b, "@@ %s @@\n", edit.OldPath)
+			writeLines(&b, "-", edit.Old.String())
+		case MoveElement:
+			fmt.Fprintf(&b, "@@ %s -> %s @@\n", edit.OldPath, edit.NewPath)
+		case ChangeAttr:
+			fmt.Fprintf(&b, "@@ %s @@\n", edit.OldPath)
+			if edit.OldAttr != nil {
+				fmt.Fprintf(&b, "-@%s=%q\n", formatName(edit.OldAttr.Name), edit.OldAttr.Value)
+			}
+			if edit.NewAttr != nil {
+				fmt.Fprintf(&b, "+@%s=%q\n", formatName(edit.NewAttr.Name), edit.NewAttr.Value)
+			}
+		case ChangeContent:
+			fmt.Fprintf(&b, "@@ %s @@\n", edit.OldPath)
+			writeLines(&b, "-", string(edit.Old.Text()))
+			writeLines(&b, "+", string(edit.New.Text()))
+		}
+	}
+	_, err := w.Write(b.Bytes())
+	return err
+}
+
+func writeLines(b *bytes.Buffer, prefix, text string) {
+	for _, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		b.WriteString(prefix)
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+}
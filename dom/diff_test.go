@@ -0,0 +1,143 @@
+package dom
+
+import (
+	"math/rand/v2"
+	"strconv"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestDiffIdentical(t *testing.T) {
+	a := mustParse(t, `<a xmlns:p="urn:p"><p:b x="1">t</p:b></a>`)
+	b := mustParse(t, `<a xmlns:q="urn:p"><q:b x="1">t</q:b></a>`)
+	script := Diff(a, b)
+	expect.Slice(script).ToBeEmpty(t)
+	expect.String(script.Unified()).ToBe(t, "")
+}
+
+func TestDiffChanges(t *testing.T) {
+	a := mustParse(t, `<a><b x="1" y="2">old</b><c/><d/></a>`)
+	b := mustParse(t, `<a><b x="3" z="4">new</b><d/><e/></a>`)
+	script := Diff(a, b)
+
+	expect.Slice(editKinds(script)).ToBe(t, ChangeAttr, ChangeAttr, ChangeAttr, ChangeContent, DeleteElement, InsertElement)
+	expect.String(script[0].OldPath).ToBe(t, "/a[1]/b[1]")
+	expect.String(script[0].OldAttr.Value).ToBe(t, "1")
+	expect.String(script[0].NewAttr.Value).ToBe(t, "3")
+	expect.Any(script[1].NewAttr).ToBeNil(t)
+	expect.Any(script[2].OldAttr).ToBeNil(t)
+	expect.String(script[4].OldPath).ToBe(t, "/a[1]/c[1]")
+	expect.String(script[5].NewPath).ToBe(t, "/a[1]/e[1]")
+
+	expect.String(script.Unified()).ToBe(t, `--- old
++++ new
+@@ /a[1]/b[1] @@
+-@x="1"
++@x="3"
+@@ /a[1]/b[1] @@
+-@y="2"
+@@ /a[1]/b[1] @@
++@z="4"
+@@ /a[1]/b[1] @@
+-old
++new
+@@ /a[1]/c[1] @@
+-<c/>
+@@ /a[1]/e[1] @@
++<e/>
+`)
+}
+
+func TestDiffMove(t *testing.T) {
+	a := mustParse(t, `<a><item n="1"/><item n="2"/><item n="3"/></a>`)
+	b := mustParse(t, `<a><item n="2"/><item n="3"/><item n="1"/></a>`)
+	script := Diff(a, b)
+
+	expect.Slice(editKinds(script)).ToBe(t, MoveElement)
+	expect.String(script[0].OldPath).ToBe(t, "/a[1]/item[1]")
+	expect.String(script[0].NewPath).ToBe(t, "/a[1]/item[3]")
+	expect.String(script.Unified()).ToContain(t, "@@ /a[1]/item[1] -> /a[1]/item[3] @@\n")
+}
+
+func TestDiffDifferentRoots(t *testing.T) {
+	script := Diff(mustParse(t, `<a/>`), mustParse(t, `<b/>`))
+	expect.Slice(editKinds(script)).ToBe(t, DeleteElement, InsertElement)
+	expect.String(script[1].NewPath).ToBe(t, "/b[1]")
+}
+
+func TestLCS(t *testing.T) {
+	rnd := rand.New(rand.NewPCG(1, 2))
+	names := func(n int) []*Element {
+		res := make([]*Element, n)
+		for i := range res {
+			res[i] = Elem(string(rune('a'+rnd.IntN(4))), "")
+		}
+		return res
+	}
+	sameName := func(a, b *Element) bool { return a.Name == b.Name }
+
+	for range 200 {
+		a, b := names(rnd.IntN(12)), names(rnd.IntN(12))
+		pairs := lcs(a, b, sameName)
+
+		// the pairs match, are in order, and are as many as a full table gives
+		for k, p := range pairs {
+			expect.Bool(sameName(a[p[0]], b[p[1]])).ToBeTrue(t)
+			if k > 0 {
+				expect.Bool(p[0] > pairs[k-1][0] && p[1] > pairs[k-1][1]).ToBeTrue(t)
+			}
+		}
+		table := make([][]int, len(a)+1)
+		for i := range table {
+			table[i] = make([]int, len(b)+1)
+		}
+		for i := range a {
+			for j := range b {
+				if sameName(a[i], b[j]) {
+					table[i+1][j+1] = table[i][j] + 1
+				} else {
+					table[i+1][j+1] = max(table[i][j+1], table[i+1][j])
+				}
+			}
+		}
+		expect.Number(len(pairs)).ToBe(t, table[len(a)][len(b)])
+	}
+}
+
+func TestLongestIncreasing(t *testing.T) {
+	pairs := [][2]int{{5, 0}, {1, 1}, {6, 2}, {2, 3}, {3, 4}, {0, 5}, {4, 6}, {7, 7}}
+	expect.Slice(longestIncreasing(pairs)).ToBe(t, [2]int{1, 1}, [2]int{2, 3}, [2]int{3, 4}, [2]int{4, 6}, [2]int{7, 7})
+	expect.Slice(longestIncreasing(nil)).ToBeEmpty(t)
+}
+
+func TestDiffHashCollision(t *testing.T) {
+	a := mustParse(t, `<a><b>1</b></a>`)
+	b := mustParse(t, `<a><b>2</b></a>`)
+
+	// pretend that the subtrees have the same hash
+	d := &differ{hashes: map[*Element]uint64{a: 1, b: 1, a.ChildAt(0): 2, b.ChildAt(0): 2}, classes: make(map[uint64][]*Element), class: make(map[*Element]*Element)}
+	d.diff(a, b)
+	expect.Slice(editKinds(d.script)).ToBe(t, ChangeContent)
+}
+
+func TestDiffLarge(t *testing.T) {
+	a, b := Elem("a", ""), Elem("a", "")
+	for i := range 20000 {
+		a.AddChild(Elem("item", "").SetText(strconv.Itoa(i)))
+		if i%100 != 0 {
+			b.AddChild(Elem("item", "").SetText(strconv.Itoa(i)))
+		}
+	}
+	b.AddChild(Elem("last", ""))
+	script := Diff(a, b)
+	expect.Number(len(script)).ToBe(t, 201)
+}
+
+func editKinds(script EditScript) []EditKind {
+	kinds := make([]EditKind, len(script))
+	for i, edit := range script {
+		kinds[i] = edit.Kind
+	}
+	return kinds
+}
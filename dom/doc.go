@@ -3,9 +3,13 @@
 // encoding mechanism (XMLRPC, SOAP, etc.), and not for general XML document
 // processing.  Specifically:
 //
-//  1. We ignore comments and document processing directives.  They are stripped
-//     out as part of document processing.
+//  1. By default, we ignore comments and document processing directives.  They
+//     are stripped out as part of document processing, unless they are kept
+//     using [ParseOptions].
 //
-//  2. We do not have separate Text fields.  Instead, each [Element] has a single
-//     Content field that holds the contents of the text enclosed within a tag.
+//  2. We do not usually have separate Text fields.  Instead, each [Element] has a
+//     single Content field that holds the contents of the text enclosed within a tag.
+//     Where text has to be interleaved with child elements (mixed content), an
+//     [Element] can also hold a sequence of [Text] nodes and child elements; see
+//     [ParseOptions] and [Element.AddText].
 package dom
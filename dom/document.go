@@ -7,12 +7,61 @@ import (
 
 // A Document represents an entire XML document.  Documents hold the root Element.
 type Document struct {
-	root *Element
+	// Version, Encoding and Standalone are from the XML declaration, e.g.
+	// <?xml version="1.0" encoding="UTF-8" standalone="yes"?>.  If Version is
+	// empty, the default declaration <?xml version="1.0" encoding="UTF-8"?> is
+	// written.  Encoding and Standalone are omitted when they are empty.
+	Version    string
+	Encoding   string
+	Standalone string
+	// Prolog holds the comments, processing instructions and [DocType] before
+	// the root element.
+	Prolog []Node
+	// Epilog holds the comments and processing instructions after the root element.
+	Epilog []Node
+	root   *Element
+	// decl is set when the document was parsed with Preserve.
+	decl *declSource
+	ids  idIndex
+}
+
+// declSource records the XML declaration as it was parsed.
+type declSource struct {
+	raw                           string // empty if there was no declaration
+	version, encoding, standalone string
 }
 
 // CreateDocument creates a new XML document.
 func CreateDocument() *Document {
-	return &Document{}
+	return &Document{Version: "1.0", Encoding: "UTF-8"}
+}
+
+// DocType returns the document type declaration in the Prolog, or nil if there is none.
+func (doc *Document) DocType() *DocType {
+	for _, n := range doc.Prolog {
+		if dt, ok := n.(*DocType); ok {
+			return dt
+		}
+	}
+	return nil
+}
+
+// SetDocType sets the document type declaration, replacing any existing one.
+// It is removed if dt is nil.
+func (doc *Document) SetDocType(dt *DocType) {
+	for i, n := range doc.Prolog {
+		if _, ok := n.(*DocType); ok {
+			if dt == nil {
+				doc.Prolog = append(doc.Prolog[:i], doc.Prolog[i+1:]...)
+			} else {
+				doc.Prolog[i] = dt
+			}
+			return
+		}
+	}
+	if dt != nil {
+		doc.Prolog = append([]Node{dt}, doc.Prolog...)
+	}
 }
 
 // Root returns the root element of the document.
@@ -20,19 +69,49 @@ func (doc *Document) Root() (node *Element) {
 	return doc.root
 }
 
-// SetRoot sets a new root element of the document.
+// SetRoot sets a new root element of the document.  node is removed from its
+// parent or from the document it was the root of, and the document becomes
+// the owner of node and its descendants.  The previous root, if any, keeps
+// this document as its owner; see [Element.OwnerDocument].
 func (doc *Document) SetRoot(node *Element) {
-	node.parent = nil
+	doc.Adopt(node)
 	doc.root = node
+	doc.ids.generation++
+}
+
+// Adopt makes doc the owner of e and its descendants, so that e can be added
+// to the tree of doc; see [Element.OwnerDocument].  e is removed from its
+// parent, or from the document it was the root of.
+// e is returned.
+func (doc *Document) Adopt(e *Element) *Element {
+	if e.parent != nil {
+		e.parent.RemoveChild(e)
+	} else if old := e.doc; old != nil && old.root == e {
+		old.root = nil
+		old.ids.generation++
+	}
+	e.setOwner(doc)
+	return e
 }
 
 // Encode encodes the entire [Document] using the [Encoder].
 // The output is a well-formed XML document.
+//
+// The output is in the encoding of the [Encoder], UTF-8 by default; the
+// declaration names that encoding, whatever the Encoding of the document.
 func (doc *Document) Encode(e *Encoder) error {
-	_, _ = e.WriteString(`<?xml version="1.0" encoding="UTF-8"?>`)
+	doc.encodeDeclaration(e)
 	e.prettyEnd()
+	if err := encodeNodes(e, doc.Prolog); err != nil {
+		return err
+	}
 	if doc.root != nil {
-		return doc.root.Encode(e)
+		if err := doc.root.Encode(e); err != nil {
+			return err
+		}
+	}
+	if err := encodeNodes(e, doc.Epilog); err != nil {
+		return err
 	}
 	return e.Flush()
 }
@@ -61,3 +140,56 @@ func (doc *Document) bytes(indentation ...string) *bytes.Buffer {
 func (doc *Document) String() string {
 	return string(doc.Bytes("  "))
 }
+
+func (doc *Document) encodeDeclaration(e *Encoder) {
+	if e.preserve && doc.decl != nil && doc.decl.version == doc.Version &&
+		doc.decl.encoding == doc.Encoding && doc.decl.standalone == doc.Standalone &&
+		e.isEncoding(doc.Encoding) {
+		_, _ = e.WriteString(doc.decl.raw)
+		return
+	}
+
+	if doc.Version == "" {
+		_, _ = e.WriteString(`<?xml version="1.0" encoding="`)
+		_, _ = e.WriteString(e.charset.name)
+		_, _ = e.WriteString(`"?>`)
+		return
+	}
+
+	_, _ = e.WriteString(`<?xml version="`)
+	_, _ = e.WriteString(doc.Version)
+	_, _ = e.WriteString(`"`)
+	if doc.Encoding != "" || e.charset != utf8Charset {
+		encoding := doc.Encoding
+		if !e.isEncoding(encoding) {
+			encoding = e.charset.name
+		}
+		_, _ = e.WriteString(` encoding="`)
+		_, _ = e.WriteString(encoding)
+		_, _ = e.WriteString(`"`)
+	}
+	if doc.Standalone != "" {
+		_, _ = e.WriteString(` standalone="`)
+		_, _ = e.WriteString(doc.Standalone)
+		_, _ = e.WriteString(`"`)
+	}
+	_, _ = e.WriteString(`?>`)
+}
+
+// addMisc adds n to the Prolog or Epilog, depending on whether the root has been set.
+func (doc *Document) addMisc(n Node) {
+	if doc.root == nil {
+		doc.Prolog = append(doc.Prolog, n)
+	} else {
+		doc.Epilog = append(doc.Epilog, n)
+	}
+}
+
+func encodeNodes(e *Encoder, nodes []Node) error {
+	for _, n := range nodes {
+		if err := n.encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -143,3 +143,100 @@ func TestParseTooManyRootElements(t *testing.T) {
 		t.Errorf("Expected TooManyRootElements, got %v", err)
 	}
 }
+
+func TestSiblings(t *testing.T) {
+	doc := parseDoc()
+	root := doc.Root()
+	node1 := root.FirstChild()
+	node2a := root.ChildAt(1)
+	node2b := root.LastChild()
+
+	expect.Number(root.ChildCount()).ToBe(t, 3)
+	expect.Number(root.Index()).ToBe(t, -1)
+	expect.Number(node2b.Index()).ToBe(t, 2)
+	expect.Bool(node1.NextSibling() == node2a).ToBeTrue(t)
+	expect.Bool(node2a.NextSibling() == node2b).ToBeTrue(t)
+	expect.Bool(node2b.PrevSibling() == node2a).ToBeTrue(t)
+	expect.Any(node2b.NextSibling()).ToBeNil(t)
+	expect.Any(node1.PrevSibling()).ToBeNil(t)
+	expect.Any(root.NextSibling()).ToBeNil(t)
+	expect.Any(root.ChildAt(3)).ToBeNil(t)
+	expect.Any(node1.FirstChild().FirstChild()).ToBeNil(t)
+
+	root.RemoveChild(node2a)
+	expect.Number(node2b.Index()).ToBe(t, 1)
+	expect.Number(node2a.Index()).ToBe(t, -1)
+	expect.Bool(node1.NextSibling() == node2b).ToBeTrue(t)
+	expect.Any(node2a.NextSibling()).ToBeNil(t)
+}
+
+func childNames(e *Element) string {
+	var names []string
+	for _, c := range e.Children() {
+		names = append(names, c.Name.Local)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestInsertChild(t *testing.T) {
+	root := Elem("root", "").AddChildren(Elem("a", ""), Elem("b", ""))
+	a, b := root.ChildAt(0), root.ChildAt(1)
+
+	root.InsertChildAt(0, Elem("x", ""))
+	root.InsertChildAt(3, Elem("y", ""))
+	expect.String(childNames(root)).ToBe(t, "x,a,b,y")
+
+	root.InsertBefore(b, Elem("p", ""))
+	root.InsertAfter(b, Elem("q", ""))
+	expect.String(childNames(root)).ToBe(t, "x,a,p,b,q,y")
+
+	// moving an existing child
+	root.InsertChildAt(5, a)
+	expect.String(childNames(root)).ToBe(t, "x,p,b,q,y,a")
+	expect.Number(a.Index()).ToBe(t, 5)
+
+	// reparenting from elsewhere
+	other := Elem("other", "").AddChild(Elem("z", ""))
+	root.InsertAfter(root.FirstChild(), other.FirstChild())
+	expect.String(childNames(root)).ToBe(t, "x,z,p,b,q,y,a")
+	expect.Number(other.ChildCount()).ToBe(t, 0)
+
+	expect.Any(root.InsertBefore(other, Elem("n", ""))).ToBeNil(t)
+	expect.Func(func() { root.InsertChildAt(9, Elem("n", "")) }).ToPanic(t)
+}
+
+func TestReplaceAndRemoveChildAt(t *testing.T) {
+	root := Elem("root", "").AddChildren(Elem("a", ""), Elem("b", ""), Elem("c", ""))
+	b := root.ChildAt(1)
+	c := root.ChildAt(2)
+
+	old := root.ReplaceChild(b, Elem("x", ""))
+	expect.Bool(old == b).ToBeTrue(t)
+	expect.Any(b.Parent()).ToBeNil(t)
+	expect.String(childNames(root)).ToBe(t, "a,x,c")
+
+	// replacing with an existing child moves it
+	root.ReplaceChild(root.FirstChild(), c)
+	expect.String(childNames(root)).ToBe(t, "c,x")
+	expect.Number(root.ChildAt(1).Index()).ToBe(t, 1)
+
+	expect.Any(root.ReplaceChild(b, Elem("y", ""))).ToBeNil(t)
+
+	removed := root.RemoveChildAt(0)
+	expect.Bool(removed == c).ToBeTrue(t)
+	expect.String(childNames(root)).ToBe(t, "x")
+	expect.Any(root.RemoveChildAt(1)).ToBeNil(t)
+}
+
+func TestInsertMixedContent(t *testing.T) {
+	p := Elem("p", "").AddText("a").AddChild(Elem("i", "")).AddText("b")
+	i := p.FirstChild()
+
+	p.InsertAfter(i, Elem("j", ""))
+	p.InsertBefore(i, Elem("h", ""))
+	p.InsertChildAt(3, Elem("k", ""))
+	expect.String(p.Bytes()).ToEqual(t, "<p>a<h/><i/><j/>b<k/></p>")
+
+	p.ReplaceChild(i, Elem("x", ""))
+	expect.String(p.Bytes()).ToEqual(t, "<p>a<h/><x/><j/>b<k/></p>")
+}
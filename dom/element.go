@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"slices"
 )
 
 // Element represents a node in an XML document.
@@ -15,10 +16,21 @@ type Element struct {
 	Name     xml.Name
 	children []*Element
 	parent   *Element
-	// Unlike a full-fledged XML DOM, we only have a single Content field
-	// instead of representing Text nodes separately.
+	index    int // the position of this element in parent.children
+	// nodes is nil unless text has to be interleaved with the children;
+	// when present, it holds the children and the Text nodes in order.
+	nodes []Node
+	// Unlike a full-fledged XML DOM, we usually have a single Content field
+	// instead of representing Text nodes separately. See [Element.AddText]
+	// for mixed content.
 	Content    []byte
 	Attributes []xml.Attr
+	// src is set when the element was parsed with Preserve.
+	src *elementSource
+	// pos is set when the element was parsed with Positions.
+	pos *sourceSpan
+	// doc is the owner document; see [Element.OwnerDocument].
+	doc *Document
 }
 
 // CreateElement creates a new element with the passed-in [xml.Name].
@@ -76,10 +88,122 @@ func (node *Element) AddChild(child *Element) *Element {
 		child.parent.RemoveChild(child)
 	}
 	child.parent = node
+	child.setOwner(node.doc)
+	child.index = len(node.children)
 	node.children = append(node.children, child)
+	if node.nodes != nil {
+		node.nodes = append(node.nodes, child)
+	}
+	node.changed()
+	return node
+}
+
+// InsertChildAt inserts child into node so that it becomes the child at
+// position i. child will be reparented if needed; if it was already a child
+// of node, i is its position after it has been moved.  It panics if i is out
+// of range, i.e. less than zero or greater than the number of children.
+// The altered node is returned.
+func (node *Element) InsertChildAt(i int, child *Element) *Element {
+	n := len(node.children)
+	if child.parent == node {
+		n--
+	}
+	if i < 0 || i > n {
+		log.Panicf("InsertChildAt: index %d out of range [0,%d]", i, n)
+	}
+
+	if child.parent != nil {
+		child.parent.RemoveChild(child)
+	}
+	at := len(node.nodes)
+	if i < len(node.children) {
+		at = node.nodeIndex(node.children[i])
+	}
+	node.insertChild(i, at, child)
 	return node
 }
 
+// InsertBefore inserts child into node immediately before ref, which must be
+// a child of node. child will be reparented if needed.
+// The altered node is returned, or nil if ref is not a child of node.
+func (node *Element) InsertBefore(ref, child *Element) *Element {
+	if ref == nil || ref.parent != node {
+		return nil
+	}
+	if ref == child {
+		return node
+	}
+	if child.parent != nil {
+		child.parent.RemoveChild(child)
+	}
+	node.insertChild(ref.index, node.nodeIndex(ref), child)
+	return node
+}
+
+// InsertAfter inserts child into node immediately after ref, which must be
+// a child of node. child will be reparented if needed.
+// The altered node is returned, or nil if ref is not a child of node.
+func (node *Element) InsertAfter(ref, child *Element) *Element {
+	if ref == nil || ref.parent != node {
+		return nil
+	}
+	if ref == child {
+		return node
+	}
+	if child.parent != nil {
+		child.parent.RemoveChild(child)
+	}
+	node.insertChild(ref.index+1, node.nodeIndex(ref)+1, child)
+	return node
+}
+
+// ReplaceChild puts newChild in the place of oldChild, which must be a child
+// of node. newChild will be reparented if needed.
+// The removed oldChild is returned, or nil if it was not a child of node.
+func (node *Element) ReplaceChild(oldChild, newChild *Element) *Element {
+	if oldChild == nil || oldChild.parent != node {
+		return nil
+	}
+	if oldChild == newChild {
+		return oldChild
+	}
+	if newChild.parent != nil {
+		newChild.parent.RemoveChild(newChild)
+	}
+
+	i := oldChild.index
+	node.children[i] = newChild
+	newChild.parent = node
+	newChild.setOwner(node.doc)
+	newChild.index = i
+	if node.nodes != nil {
+		node.nodes[node.nodeIndex(oldChild)] = newChild
+	}
+	oldChild.parent = nil
+	oldChild.index = 0
+	node.changed()
+	return oldChild
+}
+
+// RemoveChildAt removes the child at position i.  The removed child
+// is returned, or nil if there is no such child.
+func (node *Element) RemoveChildAt(i int) *Element {
+	return node.RemoveChild(node.ChildAt(i))
+}
+
+// insertChild puts child at position i in the children and at position at in
+// the node sequence, if there is one.
+func (node *Element) insertChild(i, at int, child *Element) {
+	child.parent = node
+	child.setOwner(node.doc)
+	node.children = slices.Insert(node.children, i, child)
+	node.renumber(i)
+	if node.nodes != nil {
+		node.nodes = slices.Insert(node.nodes, at, Node(child))
+	}
+	node.changed()
+}
+
 // GetAttr returns all the matching Attrs on the node.
 func (node *Element) GetAttr(name, space, val string) []xml.Attr {
 	res := []xml.Attr{}
@@ -104,14 +228,23 @@ func (node *Element) AddChildren(children ...*Element) *Element {
 }
 
 // Replace performs an in-place replacement of node with other.
-// other should not be used after this functions returns.
+// other should not be used after this functions returns; use other.Clone()
+// if it is still needed.
 // The altered node is returned.
 func (node *Element) Replace(other *Element) *Element {
 	node.Name = other.Name
 	node.Content = other.Content
 	node.Attributes = other.Attributes
+	node.src = other.src
+	for _, c := range node.children {
+		c.parent = nil
+	}
 	node.children = []*Element{}
-	node.AddChildren(other.children...)
+	node.nodes = nil
+	for _, n := range other.Nodes() {
+		node.AddNode(n)
+	}
+	node.changed()
 	return node
 }
 
@@ -119,24 +252,29 @@ func (node *Element) Replace(other *Element) *Element {
 // will be returned if it was actually a child of node, otherwise
 // nil will be returned.
 func (node *Element) RemoveChild(child *Element) *Element {
-	p := -1
-	for i, v := range node.children {
-		if v == child {
-			p = i
-			break
-		}
-	}
-
-	if p == -1 {
+	if child == nil || child.parent != node {
 		return nil
 	}
 
+	p := child.index
 	copy(node.children[p:], node.children[p+1:])
+	node.children[len(node.children)-1] = nil
 	node.children = node.children[0 : len(node.children)-1]
+	node.renumber(p)
+	node.removeNode(child)
 	child.parent = nil
+	child.index = 0
+	node.changed()
 	return child
 }
 
+// renumber sets the index of the children from position i onwards.
+func (node *Element) renumber(i int) {
+	for ; i < len(node.children); i++ {
+		node.children[i].index = i
+	}
+}
+
 // Children returns all the children of node.
 func (node *Element) Children() (res []*Element) {
 	res = make([]*Element, 0, len(node.children))
@@ -145,15 +283,10 @@ func (node *Element) Children() (res []*Element) {
 
 // Descendants returns all descendants of node in breadth order.
 func (node *Element) Descendants() (res []*Element) {
-	res = make([]*Element, 0, len(node.children))
-	toProcess := node.Children()
-	for len(toProcess) > 0 {
-		nextToProcess := make([]*Element, 0, len(node.children))
-		for _, n := range toProcess {
-			nextToProcess = append(nextToProcess, n.Children()...)
-		}
-		res = append(res, toProcess...)
-		toProcess = nextToProcess
+	res = append(res, node.children...)
+	// res is also the queue of elements whose children are still to be added
+	for i := 0; i < len(res); i++ {
+		res = append(res, res[i].children...)
 	}
 	return res
 }
@@ -168,6 +301,56 @@ func (node *Element) Parent() *Element {
 	return node.parent
 }
 
+// ChildCount returns the number of children of node.
+func (node *Element) ChildCount() int {
+	return len(node.children)
+}
+
+// ChildAt returns the child at position i, or nil if there is no such child.
+func (node *Element) ChildAt(i int) *Element {
+	if i < 0 || i >= len(node.children) {
+		return nil
+	}
+	return node.children[i]
+}
+
+// FirstChild returns the first child of node, or nil if there are no children.
+func (node *Element) FirstChild() *Element {
+	return node.ChildAt(0)
+}
+
+// LastChild returns the last child of node, or nil if there are no children.
+func (node *Element) LastChild() *Element {
+	return node.ChildAt(len(node.children) - 1)
+}
+
+// Index returns the position of node amongst its parent's children, or -1 if
+// there is no parent.
+func (node *Element) Index() int {
+	if node.parent == nil {
+		return -1
+	}
+	return node.index
+}
+
+// NextSibling returns the child of the parent that follows node, or nil if
+// there is none.  Text and other nodes in between are skipped.
+func (node *Element) NextSibling() *Element {
+	if node.parent == nil {
+		return nil
+	}
+	return node.parent.ChildAt(node.index + 1)
+}
+
+// PrevSibling returns the child of the parent that precedes node, or nil if
+// there is none.  Text and other nodes in between are skipped.
+func (node *Element) PrevSibling() *Element {
+	if node.parent == nil {
+		return nil
+	}
+	return node.parent.ChildAt(node.index - 1)
+}
+
 // Ancestors returns all the ancestors of this node with the most distant ancestor last.
 func (node *Element) Ancestors() (res []*Element) {
 	res = make([]*Element, 0, 1)
@@ -181,19 +364,18 @@ func (node *Element) Ancestors() (res []*Element) {
 
 // AddAttr adds attr to node.
 // Duplicates are ignored. If attr has the same name as a preexisting
-// attribute, then it will replace the preexsting attribute.
+// attribute, then it will replace the preexisting attribute.
 // The altered node is returned.
 func (node *Element) AddAttr(attr xml.Attr) *Element {
-	for _, a := range node.Attributes {
-		if a == attr {
-			return node
-		}
+	for i, a := range node.Attributes {
 		if a.Name == attr.Name {
-			a.Value = attr.Value
+			node.Attributes[i].Value = attr.Value
+			node.changed()
 			return node
 		}
 	}
 	node.Attributes = append(node.Attributes, attr)
+	node.changed()
 	return node
 }
 
@@ -243,6 +425,9 @@ func namespacedName(e *Encoder, name xml.Name) string {
 	if name.Space == "xmlns" {
 		return name.Space + ":" + name.Local
 	}
+	if name.Space == xmlURL {
+		return "xml:" + name.Local
+	}
 	prefix, found := e.nsURLMap[name.Space]
 	if !found {
 		log.Panicf("No prefix found in %v for namespace %s", e.nsURLMap, name.Space)
@@ -250,65 +435,101 @@ func namespacedName(e *Encoder, name xml.Name) string {
 	return prefix + ":" + name.Local
 }
 
+// startTag writes the start tag of node, other than its closing ">".
+// The qualified name is returned.
+func (node *Element) startTag(e *Encoder, writeNamespaces bool) string {
+	qname := namespacedName(e, node.Name)
+	_, _ = fmt.Fprintf(e, "<%s", qname)
+	for _, a := range node.Attributes {
+		if a.Name.Space != "xmlns" {
+			_, _ = fmt.Fprintf(e, " %s=\"", namespacedName(e, a.Name))
+			_ = escapeAttr(e, a.Value, '"')
+			_, _ = e.WriteString(`"`)
+		}
+	}
+
+	if writeNamespaces {
+		e.writeNamespaces()
+	}
+	return qname
+}
+
 // Encode encodes an element using the passed-in [Encoder].
 // If an error occurs during encoding, that error is returned.
 func (node *Element) Encode(e *Encoder) (err error) {
-	// This could use some refactoring. but it works Well Enough(tm)
 	writeNamespaces := !e.started
-	if writeNamespaces {
+	if writeNamespaces && !e.preserve {
 		node.addNamespaces(e)
-		e.started = true
 	}
+	e.started = true
 
 	e.spaces()
 
-	_, _ = fmt.Fprintf(e, "<%s", namespacedName(e, node.Name))
-	for _, a := range node.Attributes {
-		if a.Name.Space != "xmlns" {
-			_, _ = fmt.Fprintf(e, " %s=\"%s\"", namespacedName(e, a.Name), a.Value)
-		}
-	}
-
-	if writeNamespaces {
-		for prefix, uri := range e.nsPrefixMap {
-			_, _ = fmt.Fprintf(e, " xmlns:%s=\"%s\"", prefix, uri)
-		}
+	var qname string
+	if e.preserve {
+		qname = node.preservingStartTag(e)
+		defer func() { e.scope = e.scope.parent }()
+	} else {
+		qname = node.startTag(e, writeNamespaces)
 	}
 
-	if len(node.children) == 0 && len(node.Content) == 0 {
-		ctag := "/>"
-		if len(e.indentation) > 0 {
-			ctag = "/>\n"
+	if len(node.children) == 0 && len(node.Content) == 0 && len(node.nodes) == 0 {
+		if e.preserve && node.src != nil && !node.src.selfClose {
+			_, _ = fmt.Fprintf(e, "></%s>", qname)
+		} else {
+			_, _ = e.WriteString("/>")
 		}
-		_, _ = e.WriteString(ctag)
+		e.prettyEnd()
 		return e.Flush()
 	}
 
 	_, _ = e.WriteString(">")
 
+	// indentation would alter mixed content or preserved whitespace, so it is written inline
+	inline := node.hasText() || node.preservesSpace()
+	if inline {
+		e.inline++
+	}
+
 	if len(node.Content) > 0 {
-		if err := xml.EscapeText(e, node.Content); err != nil {
+		if err := e.escapeText(node.Content); err != nil {
 			return err
 		}
 	}
 
-	if len(node.children) > 0 {
+	if len(node.nodes) > 0 || len(node.children) > 0 {
 		e.depth++
 		e.prettyEnd()
-		for _, c := range node.children {
-			if err = c.Encode(e); err != nil {
-				return err
+		if node.nodes != nil {
+			for _, n := range node.nodes {
+				if err = n.encode(e); err != nil {
+					return err
+				}
+			}
+		} else {
+			for _, c := range node.children {
+				if err = c.Encode(e); err != nil {
+					return err
+				}
 			}
 		}
 		e.depth--
 		e.spaces()
 	}
 
-	_, _ = fmt.Fprintf(e, "</%s>", namespacedName(e, node.Name))
+	if inline {
+		e.inline--
+	}
+
+	_, _ = fmt.Fprintf(e, "</%s>", qname)
 	e.prettyEnd()
 	return e.Flush()
 }
 
+func (node *Element) encode(e *Encoder) error {
+	return node.Encode(e)
+}
+
 // Bytes returns the XML encoding of this part of the tree, with optional indentation.
 func (node *Element) Bytes(indentation ...string) []byte {
 	return node.bytes(indentation...).Bytes()
@@ -2,6 +2,7 @@ package dom
 
 import (
 	"bufio"
+	"encoding/xml"
 	"fmt"
 	"io"
 	"log"
@@ -11,12 +12,18 @@ import (
 // Encoder holds the state needed to encode the DOM into a well-formed XML document.
 type Encoder struct {
 	*bufio.Writer
+	out             io.Writer // the writer passed to NewEncoder
+	charset         *charset  // the output encoding
 	depth           int
+	inline          int // >0 while writing mixed content, which must not be indented
 	indentation     string
 	started         bool
+	preserve        bool
 	namespacesAdded int
 	nsPrefixMap     map[string]string
 	nsURLMap        map[string]string
+	nsPrefixes      []string // the order in which prefixes were added
+	scope           *scope   // the namespaces in scope when preserving
 }
 
 // NewEncoder returns a new [Encoder] that will write to the [io.Writer].
@@ -26,7 +33,7 @@ type Encoder struct {
 //
 // Optional indentation may be specified.
 func NewEncoder(writer io.Writer, indentation ...string) *Encoder {
-	enc := &Encoder{Writer: bufio.NewWriter(writer)}
+	enc := &Encoder{Writer: bufio.NewWriter(writer), out: writer, charset: utf8Charset}
 	enc.nsPrefixMap = make(map[string]string)
 	enc.nsURLMap = make(map[string]string)
 	if len(indentation) > 0 {
@@ -35,11 +42,66 @@ func NewEncoder(writer io.Writer, indentation ...string) *Encoder {
 	return enc
 }
 
+// SetPreserve sets whether the encoder writes elements as they were parsed
+// using [ParseOptions] Preserve.  This mode keeps namespace prefixes and the
+// namespace declarations where they are, and keeps the quotes around
+// attribute values, the form of empty elements and the whitespace.  Elements
+// that were not parsed, or namespaces that are not in scope, are given
+// declarations as needed.  Indentation is not used when preserving.
+//
+// This must be called before encoding starts.  The encoder is returned.
+func (e *Encoder) SetPreserve(preserve bool) *Encoder {
+	if e.started {
+		log.Panic("Cannot change the encoder after encoding starts!")
+	}
+	e.preserve = preserve
+	return e
+}
+
+// SetEncoding sets the character encoding of the output, which is UTF-8 by
+// default.  The encodings supported are those of [CharsetReader].  When a
+// [Document] is encoded, its declaration names this encoding; for UTF-16, a
+// byte order mark is written first.
+//
+// Characters that cannot be represented in the encoding are written as
+// character references, e.g. "&#8364;".  These are only well-formed in text
+// and attribute values, so names, comments, CDATA sections and processing
+// instructions should only use characters that can be represented.
+//
+// This must be called before encoding starts.  If the encoding is not
+// supported, the error wraps [UnsupportedCharset].
+func (e *Encoder) SetEncoding(name string) error {
+	if e.started {
+		log.Panic("Cannot change the encoder after encoding starts!")
+	}
+	cs, err := lookupCharset(name)
+	if err != nil {
+		return err
+	}
+	e.charset = cs
+	if cs == utf8Charset {
+		e.Writer = bufio.NewWriter(e.out)
+	} else {
+		e.Writer = bufio.NewWriter(&encodingWriter{w: e.out, cs: cs})
+	}
+	return nil
+}
+
+// isEncoding returns true if name is declared for the output encoding.  An
+// empty name is taken as UTF-8.
+func (e *Encoder) isEncoding(name string) bool {
+	if name == "" {
+		return e.charset == utf8Charset
+	}
+	cs, err := lookupCharset(name)
+	return err == nil && cs == e.charset
+}
+
 func (e *Encoder) addNamespace(ns string, prefix string) {
 	if e.started {
 		log.Panic("Cannot add element namespaces after encoding starts!")
 	}
-	if ns == "" || ns == "xmlns" {
+	if ns == "" || ns == "xmlns" || ns == xmlURL {
 		return
 	}
 	if prefix != "" {
@@ -49,6 +111,7 @@ func (e *Encoder) addNamespace(ns string, prefix string) {
 		}
 		e.nsPrefixMap[prefix] = ns
 		e.nsURLMap[ns] = prefix
+		e.nsPrefixes = append(e.nsPrefixes, prefix)
 		return
 	}
 
@@ -62,18 +125,51 @@ func (e *Encoder) addNamespace(ns string, prefix string) {
 	e.namespacesAdded++
 	e.nsPrefixMap[prefix] = ns
 	e.nsURLMap[ns] = prefix
+	e.nsPrefixes = append(e.nsPrefixes, prefix)
+}
+
+// writeNamespaces writes the declarations of all the namespaces that were added.
+func (e *Encoder) writeNamespaces() {
+	written := make(map[string]bool, len(e.nsPrefixMap))
+	for _, prefix := range e.nsPrefixes {
+		if uri, found := e.nsPrefixMap[prefix]; found && !written[prefix] {
+			written[prefix] = true
+			_, _ = fmt.Fprintf(e, " xmlns:%s=\"", prefix)
+			_ = escapeAttr(e, uri, '"')
+			_, _ = e.WriteString(`"`)
+		}
+	}
+}
+
+// unusedPrefix generates a prefix that is not in scope.
+func (e *Encoder) unusedPrefix(s *scope) string {
+	for {
+		prefix := fmt.Sprintf("ns%v", e.namespacesAdded)
+		e.namespacesAdded++
+		if _, found := s.lookup(prefix); !found {
+			return prefix
+		}
+	}
+}
+
+// escapeText writes character data; whitespace is kept as it is when preserving.
+func (e *Encoder) escapeText(text []byte) error {
+	if e.preserve {
+		return escapeMinimal(e, string(text))
+	}
+	return xml.EscapeText(e, text)
 }
 
 // prettyEnd relies on bufio.Writer error propagation.
 func (e *Encoder) prettyEnd() {
-	if len(e.indentation) > 0 {
+	if len(e.indentation) > 0 && e.inline == 0 && !e.preserve {
 		_, _ = e.WriteString("\n")
 	}
 }
 
 // spaces relies on bufio.Writer error propagation.
 func (e *Encoder) spaces() {
-	if len(e.indentation) > 0 {
+	if len(e.indentation) > 0 && e.inline == 0 && !e.preserve {
 		for i := 0; i < e.depth; i++ {
 			_, _ = e.WriteString(e.indentation)
 		}
@@ -0,0 +1,232 @@
+package dom
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+)
+
+// EqualOption alters how [Equal] compares elements.
+type EqualOption func(*equalConfig)
+
+type equalConfig struct {
+	ignoreAttrOrder  bool
+	ignoreWhitespace bool
+	unorderedParents []xml.Name
+	ignoredAttrs     []xml.Name
+}
+
+// IgnoreAttrOrder makes [Equal] ignore the order of attributes.
+func IgnoreAttrOrder() EqualOption {
+	return func(c *equalConfig) {
+		c.ignoreAttrOrder = true
+	}
+}
+
+// IgnoreWhitespace makes [Equal] ignore differences in content that are only
+// whitespace.  Leading and trailing whitespace is ignored and other runs of
+// whitespace are treated as a single space.
+func IgnoreWhitespace() EqualOption {
+	return func(c *equalConfig) {
+		c.ignoreWhitespace = true
+	}
+}
+
+// IgnoreChildOrder makes [Equal] ignore the order of the children of elements
+// with any of the given names.  As with [Element.GetAttr], the Space or Local
+// part of a name may be "*" to match anything.
+func IgnoreChildOrder(parents ...xml.Name) EqualOption {
+	return func(c *equalConfig) {
+		c.unorderedParents = append(c.unorderedParents, parents...)
+	}
+}
+
+// IgnoreAttrs makes [Equal] ignore attributes with any of the given names.
+// As with [Element.GetAttr], the Space or Local part of a name may be "*" to
+// match anything.
+func IgnoreAttrs(names ...xml.Name) EqualOption {
+	return func(c *equalConfig) {
+		c.ignoredAttrs = append(c.ignoredAttrs, names...)
+	}
+}
+
+// Mismatch describes the first difference found by [Equal].
+type Mismatch struct {
+	// Path locates the element in the first tree where the difference was found;
+	// see [Element.Path].
+	Path string
+	// Position is the source position of that element, if it was recorded;
+	// see [Element.Position].
+	Position Position
+	Reason   string
+}
+
+func (m *Mismatch) Error() string {
+	if m.Position.IsValid() {
+		return fmt.Sprintf("%s (line %d, column %d): %s", m.Path, m.Position.Line, m.Position.Column, m.Reason)
+	}
+	return m.Path + ": " + m.Reason
+}
+
+func (m *Mismatch) String() string {
+	return m.Error()
+}
+
+// Equal compares two element trees semantically.  Names are compared using
+// their namespace URIs, so the prefixes that happen to be used make no
+// difference; namespace declarations are not compared as attributes.  The
+// text of each element is compared using [Element.Text], and also run by run
+// between its children, so that text that has moved past a child element is
+// a difference; comments and processing instructions are ignored.
+//
+// If the trees are not equal, the first difference is returned.
+func Equal(a, b *Element, opts ...EqualOption) (bool, *Mismatch) {
+	c := &equalConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	switch {
+	case a == nil && b == nil:
+		return true, nil
+	case a == nil || b == nil:
+		return false, &Mismatch{Path: "/", Reason: "one element is nil"}
+	}
+
+	m := c.compare(a, b)
+	return m == nil, m
+}
+
+func (c *equalConfig) compare(a, b *Element) *Mismatch {
+	if a.Name != b.Name {
+		return mismatch(a, "name %s differs from %s", formatName(a.Name), formatName(b.Name))
+	}
+
+	if m := c.compareAttrs(a, b); m != nil {
+		return m
+	}
+
+	ta, tb := a.Text(), b.Text()
+	if c.ignoreWhitespace {
+		ta, tb = collapse(ta), collapse(tb)
+	}
+	if !bytes.Equal(ta, tb) {
+		return mismatch(a, "content %q differs from %q", ta, tb)
+	}
+
+	if len(a.children) != len(b.children) {
+		return mismatch(a, "%d children differs from %d", len(a.children), len(b.children))
+	}
+
+	// the text must also be in the same places amongst the children
+	ra, rb := a.textRuns(), b.textRuns()
+	for i := range ra {
+		ta, tb := ra[i], rb[i]
+		if c.ignoreWhitespace {
+			ta, tb = collapse(ta), collapse(tb)
+		}
+		if !bytes.Equal(ta, tb) {
+			if i == len(a.children) {
+				return mismatch(a, "content %q differs from %q after the last child", ta, tb)
+			}
+			return mismatch(a, "content %q differs from %q before child %d", ta, tb, i+1)
+		}
+	}
+
+	if matchesAny(c.unorderedParents, a.Name) {
+		return c.compareUnordered(a.children, b.children)
+	}
+
+	for i := range a.children {
+		if m := c.compare(a.children[i], b.children[i]); m != nil {
+			return m
+		}
+	}
+	return nil
+}
+
+func (c *equalConfig) compareUnordered(as, bs []*Element) *Mismatch {
+	used := make([]bool, len(bs))
+	for _, a := range as {
+		found := false
+		for j, b := range bs {
+			if !used[j] && c.compare(a, b) == nil {
+				used[j] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return mismatch(a, "no matching element")
+		}
+	}
+	return nil
+}
+
+func (c *equalConfig) compareAttrs(a, b *Element) *Mismatch {
+	aa, ba := c.comparableAttrs(a), c.comparableAttrs(b)
+	if len(aa) != len(ba) {
+		return mismatch(a, "%d attributes differs from %d", len(aa), len(ba))
+	}
+
+	for i, attr := range aa {
+		other := ba[i]
+		if c.ignoreAttrOrder {
+			other = xml.Attr{}
+			for _, o := range ba {
+				if o.Name == attr.Name {
+					other = o
+				}
+			}
+		}
+		if attr.Name != other.Name {
+			if other.Name.Local == "" {
+				return mismatch(a, "attribute %s is missing", formatName(attr.Name))
+			}
+			return mismatch(a, "attribute %s differs from %s", formatName(attr.Name), formatName(other.Name))
+		}
+		if attr.Value != other.Value {
+			return mismatch(a, "attribute %s value %q differs from %q", formatName(attr.Name), attr.Value, other.Value)
+		}
+	}
+	return nil
+}
+
+// comparableAttrs returns the attributes that are not namespace declarations and are not ignored.
+func (c *equalConfig) comparableAttrs(e *Element) []xml.Attr {
+	res := make([]xml.Attr, 0, len(e.Attributes))
+	for _, a := range e.Attributes {
+		if !isNamespaceDecl(a) && !matchesAny(c.ignoredAttrs, a.Name) {
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+func mismatch(e *Element, format string, args ...any) *Mismatch {
+	return &Mismatch{Path: e.Path(), Position: e.Position(), Reason: fmt.Sprintf(format, args...)}
+}
+
+// matchesAny returns true if name matches any of the patterns, where "*"
+// matches any Space or Local part.
+func matchesAny(patterns []xml.Name, name xml.Name) bool {
+	for _, p := range patterns {
+		if (p.Space == "*" || p.Space == name.Space) && (p.Local == "*" || p.Local == name.Local) {
+			return true
+		}
+	}
+	return false
+}
+
+// collapse trims whitespace and replaces each internal run of whitespace with a single space.
+func collapse(s []byte) []byte {
+	return bytes.Join(bytes.Fields(s), []byte(" "))
+}
+
+// formatName writes a name in Clark notation, i.e. "{uri}local".
+func formatName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return "{" + name.Space + "}" + name.Local
+}
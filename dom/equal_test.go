@@ -0,0 +1,127 @@
+package dom
+
+import (
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func mustParse(t *testing.T, s string) *Element {
+	t.Helper()
+	doc, err := ParseString(s)
+	expect.Error(err).ToBeNil(t)
+	return doc.Root()
+}
+
+func TestEqualIgnoresPrefixes(t *testing.T) {
+	a := mustParse(t, `<x:a xmlns:x="urn:x" xmlns:y="urn:y"><y:b y:c="1"/></x:a>`)
+	b := mustParse(t, `<p:a xmlns:p="urn:x"><b xmlns="urn:y" xmlns:q="urn:y" q:c="1"/></p:a>`)
+
+	eq, m := Equal(a, b)
+	expect.Bool(eq).ToBeTrue(t)
+	expect.Any(m).ToBeNil(t)
+}
+
+func TestEqualReportsPath(t *testing.T) {
+	a := mustParse(t, `<a><b/><item>1</item><item>2</item><item>3</item></a>`)
+	b := mustParse(t, `<a><b/><item>1</item><item>2</item><item>4</item></a>`)
+
+	eq, m := Equal(a, b)
+	expect.Bool(eq).ToBeFalse(t)
+	expect.String(m.Path).ToBe(t, "/a[1]/item[3]")
+	expect.String(m.Error()).ToBe(t, `/a[1]/item[3]: content "3" differs from "4"`)
+
+	b = mustParse(t, `<a><b/><item>1</item><item x="y">2</item><item>3</item></a>`)
+	_, m = Equal(a, b)
+	expect.String(m.Error()).ToBe(t, "/a[1]/item[2]: 0 attributes differs from 1")
+
+	b = mustParse(t, `<a><b/><item>1</item><item>2</item></a>`)
+	_, m = Equal(a, b)
+	expect.String(m.Error()).ToBe(t, "/a[1]: 4 children differs from 3")
+
+	b = mustParse(t, `<a xmlns="urn:z"><b/><item>1</item><item>2</item><item>3</item></a>`)
+	_, m = Equal(a, b)
+	expect.String(m.Error()).ToBe(t, "/a[1]: name a differs from {urn:z}a")
+}
+
+func TestEqualAttrOrder(t *testing.T) {
+	a := mustParse(t, `<a x="1" y="2"/>`)
+	b := mustParse(t, `<a y="2" x="1"/>`)
+
+	eq, _ := Equal(a, b)
+	expect.Bool(eq).ToBeFalse(t)
+
+	eq, _ = Equal(a, b, IgnoreAttrOrder())
+	expect.Bool(eq).ToBeTrue(t)
+
+	b = mustParse(t, `<a y="2" z="1"/>`)
+	_, m := Equal(a, b, IgnoreAttrOrder())
+	expect.String(m.Reason).ToBe(t, "attribute x is missing")
+}
+
+func TestEqualIgnoreWhitespace(t *testing.T) {
+	a := mustParse(t, `<a>hello   world</a>`)
+	b := mustParse(t, "<a>hello\n world</a>")
+
+	eq, _ := Equal(a, b)
+	expect.Bool(eq).ToBeFalse(t)
+
+	eq, _ = Equal(a, b, IgnoreWhitespace())
+	expect.Bool(eq).ToBeTrue(t)
+}
+
+func TestEqualIgnoreChildOrder(t *testing.T) {
+	a := mustParse(t, `<a><set><i>1</i><i>2</i></set><list><i>1</i><i>2</i></list></a>`)
+	b := mustParse(t, `<a><set><i>2</i><i>1</i></set><list><i>1</i><i>2</i></list></a>`)
+
+	eq, m := Equal(a, b)
+	expect.Bool(eq).ToBeFalse(t)
+	expect.String(m.Path).ToBe(t, "/a[1]/set[1]/i[1]")
+
+	eq, _ = Equal(a, b, IgnoreChildOrder(xml.Name{Space: "*", Local: "set"}))
+	expect.Bool(eq).ToBeTrue(t)
+
+	b = mustParse(t, `<a><set><i>2</i><i>1</i></set><list><i>2</i><i>1</i></list></a>`)
+	_, m = Equal(a, b, IgnoreChildOrder(xml.Name{Local: "set"}))
+	expect.String(m.Path).ToBe(t, "/a[1]/list[1]/i[1]")
+
+	b = mustParse(t, `<a><set><i>2</i><i>3</i></set><list><i>1</i><i>2</i></list></a>`)
+	_, m = Equal(a, b, IgnoreChildOrder(xml.Name{Local: "set"}))
+	expect.String(m.Error()).ToBe(t, "/a[1]/set[1]/i[1]: no matching element")
+}
+
+func TestEqualIgnoreAttrs(t *testing.T) {
+	a := mustParse(t, `<a id="1" x="y"/>`)
+	b := mustParse(t, `<a id="2" x="y"/>`)
+
+	eq, _ := Equal(a, b, IgnoreAttrs(xml.Name{Local: "id"}))
+	expect.Bool(eq).ToBeTrue(t)
+}
+
+func TestEqualMixedContent(t *testing.T) {
+	opts := ParseOptions{MixedContent: true, PreserveMarkup: true}
+	parse := func(s string) *Element {
+		doc, err := opts.Parse(strings.NewReader(s))
+		expect.Error(err).ToBeNil(t)
+		return doc.Root()
+	}
+
+	a := parse(`<p>a<b/>c</p>`)
+	_, m := Equal(a, parse(`<p>ac<b/></p>`))
+	expect.String(m.Error()).ToBe(t, `/p[1]: content "a" differs from "ac" before child 1`)
+
+	_, m = Equal(a, parse(`<p><b/>ac</p>`))
+	expect.String(m.Error()).ToBe(t, `/p[1]: content "a" differs from "" before child 1`)
+
+	// adjacent text and CDATA are merged, and comments are ignored
+	eq, _ := Equal(a, parse(`<p><![CDATA[a]]><!--x--><b/>c</p>`))
+	expect.Bool(eq).ToBeTrue(t)
+	eq, _ = Equal(parse(`<p>a <b/></p>`), parse(`<p>a<b/></p>`), IgnoreWhitespace())
+	expect.Bool(eq).ToBeTrue(t)
+
+	// the same applies to Diff
+	expect.Slice(editKinds(Diff(a, parse(`<p>ac<b/></p>`)))).ToBe(t, ChangeContent)
+	expect.Slice(Diff(a, parse(`<p>a<![CDATA[]]><b/>c</p>`))).ToBeEmpty(t)
+}
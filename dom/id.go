@@ -0,0 +1,139 @@
+package dom
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"iter"
+	"strings"
+)
+
+// DuplicateID is returned when more than one element has the same ID.
+var DuplicateID = errors.New("duplicate ID")
+
+// DefaultIDAttrs are the names of the attributes that hold element IDs,
+// unless changed by [Document.SetIDAttrs].  They are xml:id, wsu:Id and
+// unqualified ID and Id attributes, as used by SAML and WS-Security.
+var DefaultIDAttrs = []xml.Name{
+	{Space: xmlURL, Local: "id"},
+	{Space: NS_WSU, Local: "Id"},
+	{Local: "ID"},
+	{Local: "Id"},
+}
+
+// idIndex maps IDs to elements.  It is rebuilt when needed, i.e. when the
+// tree has changed since it was built.
+type idIndex struct {
+	attrs      []xml.Name // nil for DefaultIDAttrs
+	elements   map[string][]*Element
+	generation uint64 // incremented whenever the tree changes
+	built      uint64 // the generation the elements were indexed at
+}
+
+// changed records that the tree containing node has been altered, so that
+// the ID index of its document, if any, is out of date.
+func (node *Element) changed() {
+	if node.doc != nil {
+		node.doc.ids.generation++
+	}
+}
+
+// SetIDAttrs sets the names of the attributes that hold element IDs,
+// replacing [DefaultIDAttrs].  As with [Element.GetAttr], the Space or Local
+// part of a name may be "*" to match anything.
+func (doc *Document) SetIDAttrs(names ...xml.Name) {
+	doc.ids.attrs = append([]xml.Name{}, names...)
+	doc.InvalidateIDs()
+}
+
+// InvalidateIDs discards the ID index, so that it is rebuilt when next
+// needed.  The index is kept up to date automatically when elements and
+// attributes are altered using the methods of [Element], but not when the
+// Attributes of an element are altered directly; call this afterwards.
+func (doc *Document) InvalidateIDs() {
+	doc.ids.generation++
+}
+
+// ElementByID returns the element with the given ID, or nil if there is
+// none.  The attributes that hold IDs are [DefaultIDAttrs] unless changed
+// using [Document.SetIDAttrs].  The index of IDs is built when first needed
+// and rebuilt after the document has been altered.
+//
+// If more than one element has the ID, the first is returned along with an
+// error that wraps [DuplicateID].
+func (doc *Document) ElementByID(id string) (*Element, error) {
+	elements := doc.idIndex()[id]
+	switch len(elements) {
+	case 0:
+		return nil, nil
+	case 1:
+		return elements[0], nil
+	}
+	return elements[0], duplicateID(id, elements)
+}
+
+// CheckIDs returns an error for each ID that is used by more than one
+// element, or nil if the IDs are unique.
+func (doc *Document) CheckIDs() error {
+	index := doc.idIndex()
+	var errs []error
+	reported := make(map[string]bool)
+	for e := range doc.preOrder() {
+		for _, id := range doc.idsOf(e) {
+			if elements := index[id]; len(elements) > 1 && !reported[id] {
+				reported[id] = true
+				errs = append(errs, duplicateID(id, elements))
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func duplicateID(id string, elements []*Element) error {
+	locations := make([]string, len(elements))
+	for i, e := range elements {
+		locations[i] = e.Location()
+	}
+	return fmt.Errorf("%w %q on %s", DuplicateID, id, strings.Join(locations, ", "))
+}
+
+// idIndex returns the ID index, building it if it is out of date.
+func (doc *Document) idIndex() map[string][]*Element {
+	if doc.ids.elements != nil && doc.ids.built == doc.ids.generation {
+		return doc.ids.elements
+	}
+
+	doc.ids.elements = make(map[string][]*Element)
+	for e := range doc.preOrder() {
+		for _, id := range doc.idsOf(e) {
+			if elements := doc.ids.elements[id]; len(elements) == 0 || elements[len(elements)-1] != e {
+				doc.ids.elements[id] = append(elements, e)
+			}
+		}
+	}
+	doc.ids.built = doc.ids.generation
+	return doc.ids.elements
+}
+
+// idsOf returns the IDs of e; usually there is at most one.
+func (doc *Document) idsOf(e *Element) []string {
+	attrs := doc.ids.attrs
+	if attrs == nil {
+		attrs = DefaultIDAttrs
+	}
+	var ids []string
+	for _, a := range e.Attributes {
+		if matchesAny(attrs, a.Name) {
+			ids = append(ids, strings.TrimSpace(a.Value))
+		}
+	}
+	return ids
+}
+
+// preOrder returns an iterator over the elements of the document in document order.
+func (doc *Document) preOrder() iter.Seq[*Element] {
+	if doc.root == nil {
+		return func(yield func(*Element) bool) {}
+	}
+	return doc.root.PreOrder()
+}
@@ -0,0 +1,93 @@
+package dom
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+const idDoc = `<Envelope xmlns:wsu="` + NS_WSU + `">
+  <Header><Timestamp wsu:Id="ts-1"/></Header>
+  <Body Id="body"><Assertion ID=" a-1 "/><p xml:id="p1" id="other"/></Body>
+</Envelope>`
+
+func parseIDDoc(t *testing.T) *Document {
+	t.Helper()
+	doc, err := ParseString(idDoc)
+	expect.Error(err).ToBeNil(t)
+	return doc
+}
+
+func TestElementByID(t *testing.T) {
+	doc := parseIDDoc(t)
+
+	for id, name := range map[string]string{"ts-1": "Timestamp", "body": "Body", "a-1": "Assertion", "p1": "p"} {
+		e, err := doc.ElementByID(id)
+		expect.Error(err).ToBeNil(t)
+		expect.String(e.Name.Local).I(id).ToBe(t, name)
+	}
+
+	e, err := doc.ElementByID("other")
+	expect.Error(err).ToBeNil(t)
+	expect.Any(e).ToBeNil(t)
+	expect.Error(doc.CheckIDs()).ToBeNil(t)
+}
+
+func TestElementByIDConfigured(t *testing.T) {
+	doc := parseIDDoc(t)
+	doc.SetIDAttrs(xml.Name{Space: "*", Local: "id"})
+
+	e, _ := doc.ElementByID("other")
+	expect.String(e.Name.Local).ToBe(t, "p")
+	e, _ = doc.ElementByID("p1")
+	expect.String(e.Name.Local).ToBe(t, "p")
+	e, _ = doc.ElementByID("body")
+	expect.Any(e).ToBeNil(t)
+}
+
+func TestElementByIDTracksChanges(t *testing.T) {
+	doc := parseIDDoc(t)
+	body, _ := doc.ElementByID("body")
+
+	body.AddChild(Elem("new", "").Attr("Id", "", "n"))
+	e, _ := doc.ElementByID("n")
+	expect.String(e.Name.Local).ToBe(t, "new")
+
+	body.RemoveChild(e)
+	e, _ = doc.ElementByID("n")
+	expect.Any(e).ToBeNil(t)
+
+	body.SetAttr("Id", "", "renamed")
+	e, _ = doc.ElementByID("renamed")
+	expect.Bool(e == body).ToBeTrue(t)
+
+	body.RemoveAttr("Id", "")
+	e, _ = doc.ElementByID("renamed")
+	expect.Any(e).ToBeNil(t)
+
+	body.Attributes = append(body.Attributes, Attr("Id", "", "direct"))
+	doc.InvalidateIDs()
+	e, _ = doc.ElementByID("direct")
+	expect.Bool(e == body).ToBeTrue(t)
+
+	doc.SetRoot(Elem("r", "").Attr("ID", "", "root"))
+	e, _ = doc.ElementByID("root")
+	expect.String(e.Name.Local).ToBe(t, "r")
+}
+
+func TestDuplicateIDs(t *testing.T) {
+	doc, err := ParseOptions{Positions: true}.Parse(strings.NewReader(`<a><b Id="x"/><c ID="x"/><d Id="y"/></a>`))
+	expect.Error(err).ToBeNil(t)
+
+	e, err := doc.ElementByID("x")
+	expect.String(e.Name.Local).ToBe(t, "b")
+	expect.Bool(errors.Is(err, DuplicateID)).ToBeTrue(t)
+	expect.String(err.Error()).ToBe(t, `duplicate ID "x" on /a[1]/b[1] (line 1, column 4), /a[1]/c[1] (line 1, column 15)`)
+
+	expect.Bool(errors.Is(doc.CheckIDs(), DuplicateID)).ToBeTrue(t)
+	doc.Root().ChildAt(1).RemoveAttr("ID", "")
+	expect.Error(doc.CheckIDs()).ToBeNil(t)
+}
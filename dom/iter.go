@@ -0,0 +1,111 @@
+package dom
+
+import "iter"
+
+// The iterators below visit elements without building slices, so a loop can
+// stop early cheaply.  The tree must not be altered during iteration; use
+// [Walk] when elements are to be added or removed along the way.
+
+// ChildrenSeq returns an iterator over the children of node, in order.
+func (node *Element) ChildrenSeq() iter.Seq[*Element] {
+	return func(yield func(*Element) bool) {
+		for _, c := range node.children {
+			if !yield(c) {
+				return
+			}
+		}
+	}
+}
+
+// PreOrder returns an iterator over node and its descendants in depth-first
+// pre-order, i.e. document order, where each element comes before its children.
+func (node *Element) PreOrder() iter.Seq[*Element] {
+	return func(yield func(*Element) bool) {
+		for e := node; e != nil; e = e.nextInPreOrder(node) {
+			if !yield(e) {
+				return
+			}
+		}
+	}
+}
+
+// nextInPreOrder finds the element after e in the pre-order traversal of root.
+func (e *Element) nextInPreOrder(root *Element) *Element {
+	if len(e.children) > 0 {
+		return e.children[0]
+	}
+	for ; e != root; e = e.parent {
+		if next := e.NextSibling(); next != nil {
+			return next
+		}
+	}
+	return nil
+}
+
+// PostOrder returns an iterator over node and its descendants in depth-first
+// post-order, where each element comes after its children.
+func (node *Element) PostOrder() iter.Seq[*Element] {
+	return func(yield func(*Element) bool) {
+		e := node.firstLeaf()
+		for {
+			if !yield(e) || e == node {
+				return
+			}
+			if next := e.NextSibling(); next != nil {
+				e = next.firstLeaf()
+			} else {
+				e = e.parent
+			}
+		}
+	}
+}
+
+// firstLeaf follows the first children down from e to an element with no children.
+func (e *Element) firstLeaf() *Element {
+	for len(e.children) > 0 {
+		e = e.children[0]
+	}
+	return e
+}
+
+// BreadthFirst returns an iterator over node and its descendants in breadth
+// order, i.e. the same order as [Element.All].
+func (node *Element) BreadthFirst() iter.Seq[*Element] {
+	return func(yield func(*Element) bool) {
+		queue := []*Element{node}
+		for len(queue) > 0 {
+			e := queue[0]
+			queue = append(queue[1:], e.children...)
+			if !yield(e) {
+				return
+			}
+		}
+	}
+}
+
+// AncestorsSeq returns an iterator over the ancestors of node, starting with
+// its parent and ending with the most distant ancestor.
+func (node *Element) AncestorsSeq() iter.Seq[*Element] {
+	return func(yield func(*Element) bool) {
+		for e := node.parent; e != nil; e = e.parent {
+			if !yield(e) {
+				return
+			}
+		}
+	}
+}
+
+// Siblings returns an iterator over the other children of the parent of
+// node, in order.  If node has no parent, there are none.
+func (node *Element) Siblings() iter.Seq[*Element] {
+	return func(yield func(*Element) bool) {
+		if node.parent == nil {
+			return
+		}
+		for _, c := range node.parent.children {
+			if c != node && !yield(c) {
+				return
+			}
+		}
+	}
+}
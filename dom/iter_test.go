@@ -0,0 +1,63 @@
+package dom
+
+import (
+	"iter"
+	"slices"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+// tree is a > (b > (d, e), c > f)
+func iterTree(t *testing.T) *Element {
+	return mustParse(t, `<a><b><d/><e/></b><c><f/></c></a>`)
+}
+
+func seqNames(seq iter.Seq[*Element]) []string {
+	var names []string
+	for e := range seq {
+		names = append(names, e.Name.Local)
+	}
+	return names
+}
+
+func TestTraversalOrders(t *testing.T) {
+	a := iterTree(t)
+	expect.Slice(seqNames(a.PreOrder())).ToBe(t, "a", "b", "d", "e", "c", "f")
+	expect.Slice(seqNames(a.PostOrder())).ToBe(t, "d", "e", "b", "f", "c", "a")
+	expect.Slice(seqNames(a.BreadthFirst())).ToBe(t, "a", "b", "c", "d", "e", "f")
+	expect.Slice(seqNames(slices.Values(a.All()))).ToBe(t, "a", "b", "c", "d", "e", "f")
+
+	// a subtree is traversed without leaving it
+	b := a.ChildAt(0)
+	expect.Slice(seqNames(b.PreOrder())).ToBe(t, "b", "d", "e")
+	expect.Slice(seqNames(b.PostOrder())).ToBe(t, "d", "e", "b")
+
+	leaf := b.ChildAt(1)
+	expect.Slice(seqNames(leaf.PreOrder())).ToBe(t, "e")
+	expect.Slice(seqNames(leaf.PostOrder())).ToBe(t, "e")
+}
+
+func TestRelativeSeqs(t *testing.T) {
+	a := iterTree(t)
+	e := a.ChildAt(0).ChildAt(1)
+
+	expect.Slice(seqNames(a.ChildrenSeq())).ToBe(t, "b", "c")
+	expect.Slice(seqNames(e.AncestorsSeq())).ToBe(t, "b", "a")
+	expect.Slice(seqNames(a.ChildAt(0).Siblings())).ToBe(t, "c")
+	expect.Slice(seqNames(a.Siblings())).ToBeEmpty(t)
+}
+
+func TestSeqStopsEarly(t *testing.T) {
+	a := iterTree(t)
+	for _, seq := range []iter.Seq[*Element]{a.PreOrder(), a.PostOrder(), a.BreadthFirst(), a.ChildrenSeq()} {
+		n := 0
+		for range seq {
+			n++
+			if n == 2 {
+				break
+			}
+		}
+		expect.Number(n).ToBe(t, 2)
+	}
+}
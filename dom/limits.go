@@ -0,0 +1,194 @@
+package dom
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	// DepthLimitExceeded is wrapped by the [LimitError] for [Limits] MaxDepth.
+	DepthLimitExceeded = errors.New("element depth limit exceeded")
+	// ElementLimitExceeded is wrapped by the [LimitError] for [Limits] MaxElements.
+	ElementLimitExceeded = errors.New("element count limit exceeded")
+	// AttrLimitExceeded is wrapped by the [LimitError] for [Limits] MaxAttrs.
+	AttrLimitExceeded = errors.New("attribute count limit exceeded")
+	// AttrLengthLimitExceeded is wrapped by the [LimitError] for [Limits] MaxAttrLength.
+	AttrLengthLimitExceeded = errors.New("attribute length limit exceeded")
+	// TextLengthLimitExceeded is wrapped by the [LimitError] for [Limits] MaxTextLength.
+	TextLengthLimitExceeded = errors.New("text length limit exceeded")
+	// NameLengthLimitExceeded is wrapped by the [LimitError] for [Limits] MaxNameLength.
+	NameLengthLimitExceeded = errors.New("name length limit exceeded")
+	// InputLimitExceeded is wrapped by the [LimitError] for [Limits] MaxInputBytes.
+	InputLimitExceeded = errors.New("input size limit exceeded")
+)
+
+// Limits guards against hostile input by bounding the resources used to
+// parse it.  Each limit is ignored when it is zero, as it is by default.
+// They apply to [ParseOptions.Parse], [ParseOptions.ParseElements] and
+// [ParseOptions.Stream] alike; when streaming, MaxElements and MaxInputBytes
+// apply to the whole input, not to each matching element.
+//
+// A limit that is exceeded is reported as a [LimitError].
+//
+// The limits on the size of a token, i.e. MaxAttrLength, MaxTextLength and
+// MaxNameLength, are checked after encoding/xml has read the whole token
+// into memory.  They keep large tokens out of the tree, but it is
+// MaxInputBytes that bounds the memory used while parsing.
+type Limits struct {
+	// MaxDepth is the maximum nesting depth of elements; the root element is
+	// at depth 1.
+	MaxDepth int
+	// MaxElements is the maximum number of elements.
+	MaxElements int
+	// MaxAttrs is the maximum number of attributes of an element, including
+	// namespace declarations.
+	MaxAttrs int
+	// MaxAttrLength is the maximum length, in bytes, of an attribute value.
+	MaxAttrLength int
+	// MaxTextLength is the maximum length, in bytes, of a single run of text,
+	// a CDATA section or a comment.  As with MaxAttrLength, it is checked
+	// only after the whole token has been read.
+	MaxTextLength int
+	// MaxNameLength is the maximum length, in bytes, of the qualified name of
+	// an element or attribute, i.e. its prefix, if any, and local name.
+	MaxNameLength int
+	// MaxInputBytes is the maximum size of the input.  When the decoder is
+	// created by this package, reading stops as soon as the limit is
+	// exceeded.  Otherwise, i.e. with [ParseOptions.ParseWithDecoder] and
+	// similar, it is checked after each token, so a very large token is
+	// still read into memory before being rejected.
+	MaxInputBytes int64
+}
+
+// LimitError is returned when the input exceeds one of the [Limits].  It
+// wraps the sentinel error for the limit, e.g. [DepthLimitExceeded], so can
+// be tested using [errors.Is].
+type LimitError struct {
+	Err      error    // the sentinel error for the limit
+	Limit    int64    // the value of the limit
+	Position Position // where the offending token starts
+}
+
+func (e *LimitError) Error() string {
+	if e.Position.IsValid() {
+		return fmt.Sprintf("%s at line %d, column %d", e.message(), e.Position.Line, e.Position.Column)
+	}
+	return e.message()
+}
+
+// message returns the error message without the position.
+func (e *LimitError) message() string {
+	return fmt.Sprintf("%v (%d)", e.Err, e.Limit)
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
+
+// limitError returns a LimitError at the start of the current token.
+func (p *parser) limitError(err error, limit int64) error {
+	return &LimitError{Err: err, Limit: limit, Position: p.tokenStart()}
+}
+
+// checkLimits returns an error if tok exceeds any of the limits.
+func (p *parser) checkLimits(tok xml.Token) error {
+	lim := p.opts.Limits
+	if lim.MaxInputBytes > 0 && !p.limitedInput && p.decoder.InputOffset() > lim.MaxInputBytes {
+		return p.limitError(InputLimitExceeded, lim.MaxInputBytes)
+	}
+
+	switch rt := tok.(type) {
+	case xml.StartElement:
+		p.elements++
+		switch {
+		case lim.MaxDepth > 0 && len(p.open) > lim.MaxDepth:
+			return p.limitError(DepthLimitExceeded, int64(lim.MaxDepth))
+		case lim.MaxElements > 0 && p.elements > lim.MaxElements:
+			return p.limitError(ElementLimitExceeded, int64(lim.MaxElements))
+		case lim.MaxAttrs > 0 && len(rt.Attr) > lim.MaxAttrs:
+			return p.limitError(AttrLimitExceeded, int64(lim.MaxAttrs))
+		case lim.MaxNameLength > 0 && p.qnameLength(rt.Name) > lim.MaxNameLength:
+			return p.limitError(NameLengthLimitExceeded, int64(lim.MaxNameLength))
+		}
+		for _, a := range rt.Attr {
+			switch {
+			case lim.MaxNameLength > 0 && p.qnameLength(a.Name) > lim.MaxNameLength:
+				return p.limitError(NameLengthLimitExceeded, int64(lim.MaxNameLength))
+			case lim.MaxAttrLength > 0 && len(a.Value) > lim.MaxAttrLength:
+				return p.limitError(AttrLengthLimitExceeded, int64(lim.MaxAttrLength))
+			}
+		}
+
+	case xml.CharData:
+		if lim.MaxTextLength > 0 && len(rt) > lim.MaxTextLength {
+			return p.limitError(TextLengthLimitExceeded, int64(lim.MaxTextLength))
+		}
+
+	case xml.Comment:
+		if lim.MaxTextLength > 0 && len(rt) > lim.MaxTextLength {
+			return p.limitError(TextLengthLimitExceeded, int64(lim.MaxTextLength))
+		}
+	}
+	return nil
+}
+
+// qnameLength returns the length of name as it was written in the input,
+// i.e. with its prefix, if any.  The decoder replaces a declared prefix with
+// its namespace URI, so the prefix is found from the declarations of the open
+// elements; an undeclared prefix is left in place of the URI.
+func (p *parser) qnameLength(name xml.Name) int {
+	switch name.Space {
+	case "":
+		return len(name.Local)
+	case xmlURL:
+		return len("xml:") + len(name.Local)
+	case "xmlns":
+		return len("xmlns:") + len(name.Local)
+	}
+	for i := len(p.open) - 1; i >= 0; i-- {
+		for _, a := range p.open[i].ns {
+			if a.Value == name.Space {
+				if a.Name.Space == "" {
+					return len(name.Local) // the default namespace
+				}
+				return len(a.Name.Local) + 1 + len(name.Local)
+			}
+		}
+	}
+	return len(name.Space) + 1 + len(name.Local)
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// limitedReader is like [io.LimitedReader] except that it returns an error,
+// rather than EOF, when there is more input than allowed.
+type limitedReader struct {
+	r     io.Reader
+	limit int64
+	n     int64 // bytes remaining
+}
+
+func newLimitedReader(r io.Reader, limit int64) *limitedReader {
+	return &limitedReader{r: r, limit: limit, n: limit}
+}
+
+func (l *limitedReader) Read(b []byte) (int, error) {
+	if l.n <= 0 {
+		// the limit has been reached, which is only a problem if there is more input
+		var one [1]byte
+		n, err := l.r.Read(one[:])
+		if n > 0 {
+			return 0, &LimitError{Err: InputLimitExceeded, Limit: l.limit}
+		}
+		return 0, err
+	}
+
+	if int64(len(b)) > l.n {
+		b = b[:l.n]
+	}
+	n, err := l.r.Read(b)
+	l.n -= int64(n)
+	return n, err
+}
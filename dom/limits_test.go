@@ -0,0 +1,97 @@
+package dom
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestLimits(t *testing.T) {
+	const input = `<a x="1" y="22"><bb>text</bb><!--note--><c><d/></c></a>`
+
+	cases := []struct {
+		limits Limits
+		want   error
+	}{
+		{Limits{MaxDepth: 2}, DepthLimitExceeded},
+		{Limits{MaxElements: 3}, ElementLimitExceeded},
+		{Limits{MaxAttrs: 1}, AttrLimitExceeded},
+		{Limits{MaxAttrLength: 1}, AttrLengthLimitExceeded},
+		{Limits{MaxTextLength: 3}, TextLengthLimitExceeded},
+		{Limits{MaxNameLength: 1}, NameLengthLimitExceeded},
+		{Limits{MaxInputBytes: 20}, InputLimitExceeded},
+	}
+
+	for _, c := range cases {
+		opts := ParseOptions{Limits: c.limits}
+
+		_, err := opts.Parse(strings.NewReader(input))
+		expect.Bool(errors.Is(err, c.want)).I(c.want.Error()).ToBeTrue(t)
+		var le *LimitError
+		expect.Bool(errors.As(err, &le)).ToBeTrue(t)
+		expect.Bool(le.Position.IsValid()).I(c.want.Error()).ToBeTrue(t)
+
+		_, err = opts.ParseElements(strings.NewReader(input))
+		expect.Bool(errors.Is(err, c.want)).I(c.want.Error()).ToBeTrue(t)
+
+		_, err = opts.ParseWithDecoder(xml.NewDecoder(strings.NewReader(input)))
+		expect.Bool(errors.Is(err, c.want)).I(c.want.Error()).ToBeTrue(t)
+
+		err = opts.Stream(strings.NewReader(input), func(*Element) bool { return false }, nil)
+		expect.Bool(errors.Is(err, c.want)).I(c.want.Error()).ToBeTrue(t)
+	}
+
+	// the input is within all these limits
+	opts := ParseOptions{Limits: Limits{
+		MaxDepth:      3,
+		MaxElements:   5,
+		MaxAttrs:      2,
+		MaxAttrLength: 2,
+		MaxTextLength: 4,
+		MaxNameLength: 2,
+		MaxInputBytes: int64(len(input)),
+	}}
+	_, err := opts.Parse(strings.NewReader(input))
+	expect.Error(err).ToBeNil(t)
+}
+
+func TestNameLengthLimit(t *testing.T) {
+	cases := []struct {
+		input string
+		ok    bool
+	}{
+		{`<p:abcde xmlns:p="urn:p"/>`, true},
+		{`<p:abcdef xmlns:p="urn:p"/>`, false},
+		{`<a xmlns:p="urn:p" p:bcdef="1"/>`, true},
+		{`<a xmlns:p="urn:p"><b p:bcdefg="1"/></a>`, false},
+		{`<abcdefg xmlns="urn:d" xml:id="x"/>`, true},
+		{`<a><aaaaaaa:x/></a>`, false},
+		{`<a aaaaaa:x="1"/>`, false},
+		{`<a xmlns:longer="urn:p"/>`, false},
+	}
+
+	opts := ParseOptions{Limits: Limits{MaxNameLength: 7}}
+	for _, c := range cases {
+		_, err := opts.Parse(strings.NewReader(c.input))
+		expect.Bool(err == nil).I(c.input).ToBe(t, c.ok)
+		if !c.ok {
+			expect.Bool(errors.Is(err, NameLengthLimitExceeded)).I(c.input).ToBeTrue(t)
+		}
+	}
+}
+
+func TestLimitError(t *testing.T) {
+	_, err := ParseOptions{Limits: Limits{MaxDepth: 1}}.Parse(strings.NewReader("<a>\n  <b/>\n</a>"))
+	expect.String(err.Error()).ToBe(t, "element depth limit exceeded (1) at line 2, column 3 in /a")
+
+	var le *LimitError
+	expect.Bool(errors.As(err, &le)).ToBeTrue(t)
+	expect.String(le.Error()).ToBe(t, "element depth limit exceeded (1) at line 2, column 3")
+
+	// there is no path outside the elements
+	_, err = ParseOptions{Limits: Limits{MaxElements: 1}}.ParseElements(strings.NewReader("<a/><c/>"))
+	expect.String(err.Error()).ToBe(t, "element count limit exceeded (1) at line 1, column 5")
+}
@@ -1,7 +1,103 @@
 package dom
 
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strings"
+)
+
 const (
 	NS_XS  = "http://www.w3.org/2001/XMLSchema"
 	NS_XSI = "http://www.w3.org/2001/XMLSchema-instance"
 	NS_XSD = "http://www.w3.org/2001/XMLSchema-datatypes"
+
+	// NS_WSU is the namespace of the WS-Security utility attributes, such as wsu:Id.
+	NS_WSU = "http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd"
 )
+
+// UndeclaredPrefix is returned when a QName uses a prefix that is not in scope.
+var UndeclaredPrefix = errors.New("undeclared namespace prefix")
+
+// LookupNamespaceURI returns the namespace URI bound to prefix in the scope
+// of node, i.e. by the nearest xmlns declaration on node or its ancestors.
+// The empty prefix gives the default namespace.  The "xml" prefix is always
+// bound.  If the prefix is not bound, or has been undeclared using xmlns="",
+// the result is false.
+func (node *Element) LookupNamespaceURI(prefix string) (string, bool) {
+	if prefix == "xml" {
+		return xmlURL, true
+	}
+	for e := node; e != nil; e = e.parent {
+		for _, a := range e.Attributes {
+			if isNamespaceDecl(a) && declaredPrefix(a) == prefix {
+				return a.Value, a.Value != ""
+			}
+		}
+	}
+	return "", false
+}
+
+// LookupPrefix returns a prefix bound to uri in the scope of node, preferring
+// the nearest declaration.  The empty prefix is returned if uri is the default
+// namespace.  If no prefix is bound to uri, the result is false.
+func (node *Element) LookupPrefix(uri string) (string, bool) {
+	if uri == "" {
+		return "", false
+	}
+	if uri == xmlURL {
+		return "xml", true
+	}
+	for e := node; e != nil; e = e.parent {
+		for _, a := range e.Attributes {
+			if isNamespaceDecl(a) && a.Value == uri {
+				// the prefix may have been redeclared nearer to node
+				prefix := declaredPrefix(a)
+				if bound, _ := node.LookupNamespaceURI(prefix); bound == uri {
+					return prefix, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// InScopeNamespaces returns all the namespaces in scope for node as a map
+// from prefix to URI.  The default namespace, if any, has the empty prefix.
+// The "xml" prefix is always included.
+func (node *Element) InScopeNamespaces() map[string]string {
+	res := map[string]string{"xml": xmlURL}
+	seen := make(map[string]bool)
+	for e := node; e != nil; e = e.parent {
+		for _, a := range e.Attributes {
+			if isNamespaceDecl(a) {
+				prefix := declaredPrefix(a)
+				if !seen[prefix] {
+					seen[prefix] = true
+					if a.Value != "" {
+						res[prefix] = a.Value
+					}
+				}
+			}
+		}
+	}
+	return res
+}
+
+// ResolveQName resolves a QName-valued string, such as the value of an
+// xsi:type attribute, into an [xml.Name] using the namespaces in scope for
+// node.  An unprefixed name is in the default namespace, if there is one.
+// [UndeclaredPrefix] is returned if the prefix is not in scope.
+func (node *Element) ResolveQName(qname string) (xml.Name, error) {
+	qname = strings.TrimSpace(qname)
+	prefix, local, found := strings.Cut(qname, ":")
+	if !found {
+		uri, _ := node.LookupNamespaceURI("")
+		return xml.Name{Space: uri, Local: qname}, nil
+	}
+	uri, ok := node.LookupNamespaceURI(prefix)
+	if !ok {
+		return xml.Name{}, fmt.Errorf("%w %q in %q", UndeclaredPrefix, prefix, qname)
+	}
+	return xml.Name{Space: uri, Local: local}, nil
+}
@@ -0,0 +1,84 @@
+package dom
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+const scopedDoc = `<a xmlns="urn:default" xmlns:p="urn:p" xmlns:q="urn:q">
+  <b xmlns:p="urn:p2" xmlns:r="urn:q">
+    <c xmlns="" xsi:type="p:Foo" xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"/>
+  </b>
+</a>`
+
+func TestLookupNamespaceURI(t *testing.T) {
+	c := mustParse(t, scopedDoc).Children()[0].Children()[0]
+
+	uri, found := c.LookupNamespaceURI("p")
+	expect.String(uri).ToBe(t, "urn:p2")
+	expect.Bool(found).ToBeTrue(t)
+
+	uri, found = c.LookupNamespaceURI("q")
+	expect.String(uri).ToBe(t, "urn:q")
+	expect.Bool(found).ToBeTrue(t)
+
+	uri, found = c.Parent().LookupNamespaceURI("")
+	expect.String(uri).ToBe(t, "urn:default")
+	expect.Bool(found).ToBeTrue(t)
+
+	_, found = c.LookupNamespaceURI("")
+	expect.Bool(found).ToBeFalse(t)
+
+	_, found = c.LookupNamespaceURI("z")
+	expect.Bool(found).ToBeFalse(t)
+
+	uri, _ = c.LookupNamespaceURI("xml")
+	expect.String(uri).ToBe(t, xmlURL)
+}
+
+func TestLookupPrefix(t *testing.T) {
+	a := mustParse(t, scopedDoc)
+	b := a.Children()[0]
+
+	prefix, found := b.LookupPrefix("urn:q")
+	expect.String(prefix).ToBe(t, "r")
+	expect.Bool(found).ToBeTrue(t)
+
+	prefix, found = a.LookupPrefix("urn:default")
+	expect.String(prefix).ToBe(t, "")
+	expect.Bool(found).ToBeTrue(t)
+
+	// p is redeclared on b
+	_, found = b.LookupPrefix("urn:p")
+	expect.Bool(found).ToBeFalse(t)
+}
+
+func TestInScopeNamespaces(t *testing.T) {
+	c := mustParse(t, scopedDoc).Children()[0].Children()[0]
+	ns := c.InScopeNamespaces()
+	expect.Map(ns).ToBe(t, map[string]string{
+		"xml": xmlURL,
+		"xsi": NS_XSI,
+		"p":   "urn:p2",
+		"q":   "urn:q",
+		"r":   "urn:q",
+	})
+}
+
+func TestResolveQName(t *testing.T) {
+	a := mustParse(t, scopedDoc)
+	c := a.Children()[0].Children()[0]
+
+	name, err := c.ResolveQName(c.GetAttr("type", NS_XSI, "*")[0].Value)
+	expect.Error(err).ToBeNil(t)
+	expect.String(formatName(name)).ToBe(t, "{urn:p2}Foo")
+
+	name, err = a.ResolveQName(" Bar ")
+	expect.Error(err).ToBeNil(t)
+	expect.String(formatName(name)).ToBe(t, "{urn:default}Bar")
+
+	_, err = c.ResolveQName("z:Baz")
+	expect.Bool(errors.Is(err, UndeclaredPrefix)).ToBeTrue(t)
+}
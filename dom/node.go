@@ -0,0 +1,282 @@
+package dom
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"strings"
+)
+
+// Node is anything that can appear in the node sequence of an [Element].
+// Child elements are held as *[Element]; character data is held as [Text]
+// or [CData]; there are also [Comment] and [ProcInst] nodes.
+//
+// Most elements never need a node sequence: their text is in the Content
+// field and their child elements are returned by [Element.Children].  A node
+// sequence is only created when text has to be interleaved with child
+// elements, e.g. "<p>Hello <b>big</b> world</p>", or when comments, processing
+// instructions or CDATA sections are kept.
+type Node interface {
+	encode(e *Encoder) error
+	clone() Node
+}
+
+// Text is a run of character data in the node sequence of an [Element].
+type Text []byte
+
+func (t Text) encode(e *Encoder) error {
+	return e.escapeText(t)
+}
+
+// isBlank returns true if t contains only whitespace.
+func (t Text) isBlank() bool {
+	return len(bytes.TrimSpace(t)) == 0
+}
+
+// isIndentation returns true if t contains only whitespace, including a line
+// break.  Blank text without a line break, such as the space in
+// "<b>a</b> <i>b</i>", is significant.
+func (t Text) isIndentation() bool {
+	return t.isBlank() && bytes.ContainsAny(t, "\r\n")
+}
+
+// CData is a CDATA section, i.e. character data that is written verbatim
+// inside "<![CDATA[" and "]]>".
+type CData []byte
+
+func (c CData) encode(e *Encoder) error {
+	_, _ = e.WriteString("<![CDATA[")
+	// "]]>" cannot appear inside a CDATA section, so it is split across two
+	data := []byte(c)
+	for {
+		i := bytes.Index(data, []byte("]]>"))
+		if i < 0 {
+			break
+		}
+		_, _ = e.Write(data[:i+2])
+		_, _ = e.WriteString("]]><![CDATA[")
+		data = data[i+2:]
+	}
+	_, _ = e.Write(data)
+	_, _ = e.WriteString("]]>")
+	return nil
+}
+
+// Comment is an XML comment.  The data excludes the "<!--" and "-->" markers.
+type Comment []byte
+
+func (c Comment) encode(e *Encoder) error {
+	if bytes.Contains(c, []byte("--")) || bytes.HasSuffix(c, []byte("-")) {
+		return errors.New("xml: comments must not contain \"--\" or end with \"-\"")
+	}
+	e.spaces()
+	_, _ = e.WriteString("<!--")
+	_, _ = e.Write(c)
+	_, _ = e.WriteString("-->")
+	e.prettyEnd()
+	return nil
+}
+
+// ProcInst is an XML processing instruction, e.g. <?xml-stylesheet href="a.xsl"?>.
+type ProcInst struct {
+	Target string
+	Inst   []byte
+}
+
+func (pi ProcInst) encode(e *Encoder) error {
+	if pi.Target == "" || strings.EqualFold(pi.Target, "xml") {
+		return fmt.Errorf("xml: invalid processing instruction target %q", pi.Target)
+	}
+	if bytes.Contains(pi.Inst, []byte("?>")) {
+		return errors.New("xml: processing instructions must not contain \"?>\"")
+	}
+	e.spaces()
+	_, _ = e.WriteString("<?")
+	_, _ = e.WriteString(pi.Target)
+	if len(pi.Inst) > 0 {
+		_, _ = e.WriteString(" ")
+		_, _ = e.Write(pi.Inst)
+	}
+	_, _ = e.WriteString("?>")
+	e.prettyEnd()
+	return nil
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// ensureNodes switches node over to holding a node sequence.  The sequence
+// is seeded with the existing children.
+func (node *Element) ensureNodes() {
+	if node.nodes == nil {
+		node.nodes = make([]Node, 0, len(node.children)+1)
+		for _, c := range node.children {
+			node.nodes = append(node.nodes, c)
+		}
+	}
+}
+
+// nodeIndex returns the position of child in the node sequence, or -1 if
+// there is no node sequence.
+func (node *Element) nodeIndex(child *Element) int {
+	for i, n := range node.nodes {
+		if n == Node(child) {
+			return i
+		}
+	}
+	return -1
+}
+
+// removeNode removes child from the node sequence, if there is one.
+func (node *Element) removeNode(child *Element) {
+	if i := node.nodeIndex(child); i >= 0 {
+		node.nodes = slices.Delete(node.nodes, i, i+1)
+	}
+}
+
+// hasText returns true if the node sequence contains any character data.
+func (node *Element) hasText() bool {
+	for _, n := range node.nodes {
+		switch n.(type) {
+		case Text, CData:
+			return true
+		}
+	}
+	return false
+}
+
+// AddNode appends n to the node sequence of node.  If n is an *[Element], this
+// is the same as [Element.AddChild].
+// The altered node is returned.
+func (node *Element) AddNode(n Node) *Element {
+	if child, ok := n.(*Element); ok {
+		return node.AddChild(child)
+	}
+	node.ensureNodes()
+	node.nodes = append(node.nodes, n)
+	return node
+}
+
+// InsertNodeAt inserts n into the node sequence of node so that it is at
+// position i in [Element.Nodes].  A node other than an element that is
+// inserted at position zero also goes before the Content.  If n is an
+// *[Element], it will be reparented if needed; if it was already a child of
+// node, i is its position after it has been moved.  It panics if i is out of
+// range, i.e. less than zero or greater than the number of nodes.
+// The altered node is returned.
+func (node *Element) InsertNodeAt(i int, n Node) *Element {
+	child, isElement := n.(*Element)
+	if isElement && child.parent != nil {
+		child.parent.RemoveChild(child)
+	}
+	node.ensureNodes()
+	if i < 0 || i > len(node.nodes) {
+		log.Panicf("InsertNodeAt: index %d out of range [0,%d]", i, len(node.nodes))
+	}
+
+	if isElement {
+		before := 0
+		for _, n := range node.nodes[:i] {
+			if _, ok := n.(*Element); ok {
+				before++
+			}
+		}
+		node.insertChild(before, i, child)
+		return node
+	}
+
+	if i == 0 && len(node.Content) > 0 {
+		node.nodes = slices.Insert(node.nodes, 0, n, Node(Text(node.Content)))
+		node.Content = nil
+		return node
+	}
+	node.nodes = slices.Insert(node.nodes, i, n)
+	return node
+}
+
+// AddText appends a [Text] node to the node sequence of node.  Unlike setting
+// Content, this allows text to be interleaved with child elements.
+// The altered node is returned.
+func (node *Element) AddText(text string) *Element {
+	return node.AddNode(Text(text))
+}
+
+// Nodes returns the node sequence of node, i.e. its child elements interleaved
+// with its [Text], [CData], [Comment] and [ProcInst] nodes.  If node has no
+// node sequence, its children are returned. The Content field is not included.
+func (node *Element) Nodes() []Node {
+	if node.nodes == nil {
+		res := make([]Node, 0, len(node.children))
+		for _, c := range node.children {
+			res = append(res, c)
+		}
+		return res
+	}
+	res := make([]Node, 0, len(node.nodes))
+	return append(res, node.nodes...)
+}
+
+// Text returns the character data directly enclosed by node.  This is the
+// Content followed by all the [Text] and [CData] nodes in the node sequence.
+// Text within descendant elements is not included.
+//
+// For elements that do not have a node sequence, this is simply Content.
+func (node *Element) Text() []byte {
+	if node.nodes == nil {
+		return node.Content
+	}
+	var b []byte
+	b = append(b, node.Content...)
+	for _, n := range node.nodes {
+		switch t := n.(type) {
+		case Text:
+			b = append(b, t...)
+		case CData:
+			b = append(b, t...)
+		}
+	}
+	return b
+}
+
+// textRuns returns the character data directly enclosed by node, split at
+// its child elements, so there is one more run than there are children.
+// Adjacent [Text] and [CData] nodes are merged, and Content is at the start
+// of the first run, where it is written.
+func (node *Element) textRuns() [][]byte {
+	runs := make([][]byte, 1, len(node.children)+1)
+	runs[0] = node.Content
+	for _, n := range node.nodes {
+		last := len(runs) - 1
+		switch t := n.(type) {
+		case Text:
+			runs[last] = append(slices.Clip(runs[last]), t...)
+		case CData:
+			runs[last] = append(slices.Clip(runs[last]), t...)
+		case *Element:
+			runs = append(runs, nil)
+		}
+	}
+	for len(runs) <= len(node.children) {
+		runs = append(runs, nil)
+	}
+	return runs
+}
+
+// SetText replaces the character data directly enclosed by node, i.e. its
+// Content and any [Text] and [CData] nodes in its node sequence.  Child
+// elements, comments and processing instructions are kept.
+// The altered node is returned.
+func (node *Element) SetText(text string) *Element {
+	node.Content = []byte(text)
+	if node.nodes != nil {
+		node.nodes = slices.DeleteFunc(node.nodes, func(n Node) bool {
+			switch n.(type) {
+			case Text, CData:
+				return true
+			}
+			return false
+		})
+	}
+	return node
+}
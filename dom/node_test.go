@@ -0,0 +1,137 @@
+package dom
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestMixedContentParse(t *testing.T) {
+	doc, err := ParseOptions{MixedContent: true}.Parse(strings.NewReader(`<p>Hello <b>big</b> world</p>`))
+	expect.Error(err).ToBeNil(t)
+
+	p := doc.Root()
+	expect.Slice(p.Children()).ToHaveLength(t, 1)
+	expect.String(p.Text()).ToEqual(t, "Hello  world")
+	expect.Any(p.Content).ToBeNil(t)
+
+	nodes := p.Nodes()
+	expect.Slice(nodes).ToHaveLength(t, 3)
+	expect.Any(nodes[0]).ToBe(t, Text("Hello "))
+	expect.Bool(nodes[1] == Node(p.Children()[0])).ToBeTrue(t)
+	expect.Any(nodes[2]).ToBe(t, Text(" world"))
+
+	expect.String(p.String()).ToBe(t, "<p>Hello <b>big</b> world</p>\n")
+}
+
+func TestMixedContentSignificantSpace(t *testing.T) {
+	const input = "<p><b>a</b> <i>b</i>\n  <q>c</q>\n</p>"
+
+	for _, ws := range []WhitespacePolicy{TrimWhitespace, CollapseWhitespace} {
+		doc, err := ParseOptions{MixedContent: true, Whitespace: ws}.Parse(strings.NewReader(input))
+		expect.Error(err).ToBeNil(t)
+
+		p := doc.Root()
+		expect.Slice(p.Nodes()).I(ws).ToHaveLength(t, 4)
+		expect.Any(p.Nodes()[1]).I(ws).ToBe(t, Text(" "))
+		expect.String(p.Bytes()).I(ws).ToEqual(t, "<p><b>a</b> <i>b</i><q>c</q></p>")
+	}
+}
+
+func TestMixedContentDefaultParse(t *testing.T) {
+	doc, err := ParseString(`<p>Hello <b>big</b> world</p>`)
+	expect.Error(err).ToBeNil(t)
+	expect.String(doc.Root().Content).ToEqual(t, "world")
+	expect.Slice(doc.Root().Nodes()).ToHaveLength(t, 1)
+}
+
+func TestMixedContentBuild(t *testing.T) {
+	root := Elem("doc", "").AddChild(
+		Elem("p", "").AddText("a ").AddChild(ElemC("i", "", "b")).AddText(" c"))
+	root.AddChild(Elem("q", ""))
+
+	expect.String(root.String()).ToBe(t, "<doc>\n  <p>a <i>b</i> c</p>\n  <q/>\n</doc>\n")
+}
+
+func TestMixedContentRemoveChild(t *testing.T) {
+	p := Elem("p", "").AddText("a")
+	i := Elem("i", "")
+	p.AddChild(i).AddText("b")
+
+	expect.Bool(p.RemoveChild(i) == i).ToBeTrue(t)
+	expect.Slice(p.Nodes()).ToHaveLength(t, 2)
+	expect.String(p.Bytes()).ToEqual(t, "<p>ab</p>")
+}
+
+func TestSetText(t *testing.T) {
+	p := Elem("p", "").AddText("a").AddChild(Elem("i", "")).AddText("b")
+	p.SetText("c")
+	expect.String(p.Text()).ToEqual(t, "c")
+	expect.Slice(p.Nodes()).ToHaveLength(t, 1)
+	expect.String(p.Bytes()).ToEqual(t, "<p>c<i/></p>")
+}
+
+func TestPreserveMarkup(t *testing.T) {
+	const input = `<?xml version="1.0" encoding="UTF-8"?>
+<?xml-stylesheet href="a.xsl"?>
+<root>
+  <!-- a comment -->
+  <a><![CDATA[x < y]]></a>
+  <?app data?>
+</root>
+<!--end-->
+`
+	doc, err := ParseOptions{PreserveMarkup: true}.Parse(strings.NewReader(input))
+	expect.Error(err).ToBeNil(t)
+
+	expect.Slice(doc.Prolog).ToBe(t, ProcInst{Target: "xml-stylesheet", Inst: []byte(`href="a.xsl"`)})
+	expect.Slice(doc.Epilog).ToBe(t, Comment("end"))
+
+	root := doc.Root()
+	nodes := root.Nodes()
+	expect.Slice(nodes).ToHaveLength(t, 3)
+	expect.Any(nodes[0]).ToBe(t, Comment(" a comment "))
+	expect.Any(nodes[2]).ToBe(t, ProcInst{Target: "app", Inst: []byte("data")})
+	expect.Slice(root.Children()[0].Nodes()).ToBe(t, CData("x < y"))
+
+	expect.String(doc.String()).ToBe(t, input)
+}
+
+func TestStripMarkup(t *testing.T) {
+	doc, err := ParseString(`<?app?><root><!-- c --><a><![CDATA[x < y]]></a></root>`)
+	expect.Error(err).ToBeNil(t)
+	expect.Slice(doc.Prolog).ToBeEmpty(t)
+	expect.Slice(doc.Root().Nodes()).ToHaveLength(t, 1)
+	expect.String(doc.Root().Children()[0].Content).ToEqual(t, "x < y")
+	expect.String(doc.Root().Bytes()).ToEqual(t, "<root><a>x &lt; y</a></root>")
+}
+
+func TestCommentEncodeError(t *testing.T) {
+	e := Elem("root", "").AddNode(Comment("a--b"))
+	var sb strings.Builder
+	err := e.Encode(NewEncoder(&sb))
+	expect.Error(err).ToContain(t, "--")
+}
+
+func TestInsertNodeAt(t *testing.T) {
+	p := Elem("p", "").SetText("text")
+	b := Elem("b", "")
+	p.AddChild(b)
+
+	p.InsertNodeAt(0, Comment("first"))
+	expect.String(p.Bytes()).ToEqual(t, "<p><!--first-->text<b/></p>")
+	expect.Any(p.Content).ToBeNil(t)
+
+	p.InsertNodeAt(2, Elem("a", ""))
+	expect.String(p.Bytes()).ToEqual(t, "<p><!--first-->text<a/><b/></p>")
+	expect.Number(b.Index()).ToBe(t, 1)
+
+	// moving an existing child
+	p.InsertNodeAt(1, b)
+	expect.String(p.Bytes()).ToEqual(t, "<p><!--first--><b/>text<a/></p>")
+	expect.Number(b.Index()).ToBe(t, 0)
+
+	p.InsertNodeAt(len(p.Nodes()), Text("end"))
+	expect.String(p.Text()).ToEqual(t, "textend")
+}
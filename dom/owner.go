@@ -0,0 +1,25 @@
+package dom
+
+// OwnerDocument returns the document that node belongs to, or nil if it does
+// not belong to one.
+//
+// An element belongs to a document when it is the root or one of its
+// descendants, or when it is added to an element that belongs to the
+// document.  As in the W3C DOM, it still belongs to the document after it is
+// removed from the tree, until it is added elsewhere or adopted using
+// [Document.Adopt].  Newly created elements belong to no document.
+func (node *Element) OwnerDocument() *Document {
+	return node.doc
+}
+
+// setOwner makes doc the owner of node and its descendants.
+func (node *Element) setOwner(doc *Document) {
+	if node.doc == doc {
+		// the descendants always have the same owner as node
+		return
+	}
+	node.doc = doc
+	for _, c := range node.children {
+		c.setOwner(doc)
+	}
+}
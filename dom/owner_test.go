@@ -0,0 +1,91 @@
+package dom
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestOwnerDocument(t *testing.T) {
+	doc, err := ParseString(`<a><b><c/></b></a>`)
+	expect.Error(err).ToBeNil(t)
+	a := doc.Root()
+	b := a.ChildAt(0)
+	c := b.ChildAt(0)
+
+	for _, e := range a.All() {
+		expect.Bool(e.OwnerDocument() == doc).ToBeTrue(t)
+	}
+
+	n := Elem("n", "").AddChild(Elem("m", ""))
+	expect.Any(n.OwnerDocument()).ToBeNil(t)
+	c.AddChild(n)
+	expect.Bool(n.OwnerDocument() == doc).ToBeTrue(t)
+	expect.Bool(n.ChildAt(0).OwnerDocument() == doc).ToBeTrue(t)
+
+	// removed elements keep their owner
+	a.RemoveChild(b)
+	expect.Bool(b.OwnerDocument() == doc).ToBeTrue(t)
+	expect.Bool(b.Clone().OwnerDocument() == doc).ToBeTrue(t)
+
+	// a detached parent passes on its owner
+	x := Elem("x", "").AddChild(b)
+	expect.Any(c.OwnerDocument()).ToBeNil(t)
+
+	a.Replace(x)
+	expect.Bool(b.OwnerDocument() == doc).ToBeTrue(t)
+	expect.Bool(b.Parent() == a).ToBeTrue(t)
+}
+
+func TestCloneKeepsIDIndex(t *testing.T) {
+	doc := parseIDDoc(t)
+	_, err := doc.ElementByID("body")
+	expect.Error(err).ToBeNil(t)
+	generation := doc.ids.generation
+
+	for _, e := range []*Element{doc.Root().Clone(), doc.Root().ShallowClone()} {
+		expect.Number(doc.ids.generation).ToBe(t, generation)
+		for d := range e.PreOrder() {
+			expect.Bool(d.OwnerDocument() == doc).ToBeTrue(t)
+		}
+	}
+
+	mixed, err := ParseOptions{MixedContent: true}.Parse(strings.NewReader(`<p>a <b>b</b> c</p>`))
+	expect.Error(err).ToBeNil(t)
+	generation = mixed.ids.generation
+	clone := mixed.Root().Clone()
+	expect.Number(mixed.ids.generation).ToBe(t, generation)
+	expect.Bool(clone.ChildAt(0).OwnerDocument() == mixed).ToBeTrue(t)
+	expect.String(clone.Bytes()).ToEqual(t, `<p>a <b>b</b> c</p>`)
+}
+
+func TestSetRootAndAdopt(t *testing.T) {
+	doc1, err := ParseString(`<a><b><c/></b></a>`)
+	expect.Error(err).ToBeNil(t)
+	doc2, err := ParseString(`<z/>`)
+	expect.Error(err).ToBeNil(t)
+
+	a := doc1.Root()
+	b := a.ChildAt(0)
+
+	// SetRoot removes b from its parent
+	doc2.SetRoot(b)
+	expect.Number(a.ChildCount()).ToBe(t, 0)
+	expect.Any(b.Parent()).ToBeNil(t)
+	expect.Bool(b.ChildAt(0).OwnerDocument() == doc2).ToBeTrue(t)
+
+	// Adopt removes a from doc1
+	expect.Bool(doc2.Adopt(a) == a).ToBeTrue(t)
+	expect.Any(doc1.Root()).ToBeNil(t)
+	expect.Bool(a.OwnerDocument() == doc2).ToBeTrue(t)
+
+	b.AddChild(a)
+	expect.String(doc2.Root().Bytes()).ToEqual(t, `<b><c/><a/></b>`)
+
+	e, _ := doc2.ElementByID("none")
+	expect.Any(e).ToBeNil(t)
+	a.SetAttr("Id", "", "moved")
+	e, _ = doc2.ElementByID("moved")
+	expect.Bool(e == a).ToBeTrue(t)
+}
@@ -4,45 +4,469 @@ import (
 	"bytes"
 	"encoding/xml"
 	"errors"
+	"fmt"
 	"io"
 	"strings"
 )
 
 var TooManyRootElements = errors.New("no more than one root element is allowed")
 
-func parseElement(decoder *xml.Decoder, tok xml.StartElement) (res *Element, err error) {
-	res = CreateElement(tok.Name)
+// ParseOptions controls how XML is parsed into a DOM.  The zero value gives the
+// behaviour of [Parse] and [ParseElements].
+type ParseOptions struct {
+	// MixedContent keeps every run of text as a [Text] node in the node
+	// sequence of its element, so that text interleaved with child elements
+	// is not lost, e.g. "<p>Hello <b>big</b> world</p>".  By default, text is
+	// kept verbatim, except that runs containing only whitespace and a line
+	// break are dropped because they are indentation.  Other blank runs, such
+	// as the space in "<b>a</b> <i>b</i>", are kept.
+	//
+	// Otherwise, the text of each element is held in its Content field, by
+	// default trimmed; if there is more than one run of text, the last
+	// non-blank one is kept.
+	MixedContent bool
+
+	// PreserveMarkup keeps comments, processing instructions and CDATA sections
+	// as [Comment], [ProcInst] and [CData] nodes.  Those before and after the
+	// root element are kept in the Prolog and Epilog of the [Document].
+	// Otherwise, comments and processing instructions are stripped and CDATA
+	// sections are treated as text.
+	//
+	// CDATA sections can only be told apart from other text when the decoder is
+	// created by this package, i.e. not by [ParseOptions.ParseWithDecoder].
+	PreserveMarkup bool
+
+	// Preserve records enough about the input for a preserving [Encoder] to
+	// reproduce it as closely as possible; see [Encoder.SetPreserve].  It
+	// implies MixedContent and PreserveMarkup, and also keeps all whitespace,
+	// including the whitespace outside the root element.
+	//
+	// The layout of tags is only recorded when the decoder is created by this
+	// package, i.e. not by [ParseOptions.ParseWithDecoder].
+	Preserve bool
+
+	// Positions records where each element starts and ends in the input;
+	// see [Element.Position].  This costs some memory, so is not done by
+	// default.
+	Positions bool
+
+	// Whitespace controls how whitespace in text is treated; see
+	// [WhitespacePolicy].  Within elements that have xml:space="preserve",
+	// whitespace is always preserved.
+	Whitespace WhitespacePolicy
+
+	// Limits bounds the resources used by parsing, to guard against hostile
+	// input.  By default, there are no limits.
+	Limits Limits
+}
+
+func (opts ParseOptions) normalised() ParseOptions {
+	if opts.Preserve {
+		opts.MixedContent = true
+		opts.PreserveMarkup = true
+	}
+	return opts
+}
+
+type parser struct {
+	decoder  *xml.Decoder
+	src      *source // nil unless the raw input is needed
+	opts     ParseOptions
+	start    int64         // input offset of the current token
+	line     int           // line of the current token
+	column   int           // column of the current token
+	open     []openElement // the open elements
+	elements int           // number of elements so far
+	recent   *recentInput  // nil unless the decoder was created by this package
+	rec      *recovery     // nil unless recovering from errors
+
+	limitedInput bool // the input is read via a limitedReader
+}
+
+func (opts ParseOptions) newParser(r io.Reader) *parser {
+	p := &parser{opts: opts.normalised()}
+	if p.opts.Limits.MaxInputBytes > 0 {
+		r = newLimitedReader(r, p.opts.Limits.MaxInputBytes)
+		p.limitedInput = true
+	}
+	r, cs := sniffCharset(r)
+	p.recent = &recentInput{r: r}
+	r = p.recent
+	if p.opts.PreserveMarkup {
+		p.src = newSource(r)
+		r = p.src
+	}
+	p.decoder = newStrictDecoder(r)
+	p.decoder.CharsetReader = func(name string, input io.Reader) (io.Reader, error) {
+		if cs != nil {
+			return input, nil // already converted to UTF-8
+		}
+		return CharsetReader(name, input)
+	}
+	return p
+}
+
+// token returns the next token from the decoder, checking it against the
+// limits.  When recovering, errors are recorded and parsing carries on.
+func (p *parser) token() (xml.Token, error) {
+	for {
+		if p.rec != nil && len(p.rec.pending) > 0 {
+			tok := p.rec.pending[0]
+			p.rec.pending = p.rec.pending[1:]
+			p.open = p.open[:len(p.open)-1]
+			return tok, nil
+		}
+
+		tok, err := p.nextToken()
+		if err == nil || err == io.EOF || p.rec == nil {
+			return tok, err
+		}
+		pe, ok := err.(*ParseError)
+		if !ok {
+			pe = p.parseError(err)
+		}
+		p.recover(pe)
+	}
+}
+
+// nextToken reads the next token from the decoder.
+func (p *parser) nextToken() (xml.Token, error) {
+	pos := p.decoderPos()
+	p.start, p.line, p.column = pos.Offset, pos.Line, pos.Column
+	if p.src != nil {
+		p.src.discard(p.start)
+	}
+	if p.rec != nil && p.rec.done {
+		return nil, io.EOF
+	}
+
+	tok, err := p.decoder.Token()
+	if err == io.EOF {
+		return nil, err
+	}
+	if err != nil {
+		var le *LimitError
+		if errors.As(err, &le) && !le.Position.IsValid() {
+			le.Position = p.tokenStart()
+		}
+		return nil, p.parseError(err)
+	}
+
+	switch rt := tok.(type) {
+	case xml.StartElement:
+		if p.rec != nil && p.rec.skip > 0 {
+			p.rec.skip-- // a synthetic start tag; see parser.restart
+			return p.nextToken()
+		}
+		p.open = append(p.open, p.openElement(rt))
+	case xml.EndElement:
+		if len(p.open) > 0 {
+			p.open = p.open[:len(p.open)-1]
+		}
+	}
+
+	if err := p.checkLimits(tok); err != nil {
+		// the path is that of the element containing the offending one
+		if _, ok := tok.(xml.StartElement); ok {
+			p.open = p.open[:len(p.open)-1]
+		}
+		return nil, p.parseError(err)
+	}
+	return tok, nil
+}
+
+// decoderPos returns the current position of the decoder in its input.
+func (p *parser) decoderPos() Position {
+	line, column := p.decoder.InputPos()
+	offset := p.decoder.InputOffset()
+	if p.rec != nil {
+		return p.rec.absolute(line, column, offset)
+	}
+	return Position{Line: line, Column: column, Offset: offset}
+}
+
+// tokenStart returns the position in the input of the start of the current token.
+func (p *parser) tokenStart() Position {
+	return p.original(Position{Line: p.line, Column: p.column, Offset: p.start})
+}
+
+// position returns the current position in the input, i.e. the end of the current token.
+func (p *parser) position() Position {
+	return p.original(p.decoderPos())
+}
+
+// original converts a position given to the decoder into one in the
+// original input, which differ when recovering from errors.
+func (p *parser) original(pos Position) Position {
+	if p.rec == nil {
+		return pos
+	}
+	return p.rec.original(pos)
+}
+
+// hasRaw returns true if the input bytes of each token are available.
+func (p *parser) hasRaw() bool {
+	return p.src != nil || p.rec != nil
+}
+
+// raw returns the input bytes of the current token, if they were recorded.
+func (p *parser) raw() []byte {
+	switch {
+	case p.rec != nil:
+		return p.rec.input[p.start:p.decoderPos().Offset]
+	case p.src != nil:
+		return p.src.bytes(p.start, p.decoder.InputOffset())
+	}
+	return nil
+}
+
+// rawOr returns the input bytes of the current token, or a reconstruction of
+// them if they were not recorded.
+func (p *parser) rawOr(reconstructed string) string {
+	if raw := p.raw(); len(raw) > 0 {
+		return string(raw)
+	}
+	return reconstructed
+}
+
+// markup converts comments and processing instructions into nodes, if they are
+// being kept.  Otherwise, nil is returned.
+func (p *parser) markup(tok xml.Token) Node {
+	if !p.opts.PreserveMarkup {
+		return nil
+	}
+	switch rt := tok.(type) {
+	case xml.Comment:
+		return Comment(rt.Copy())
+	case xml.ProcInst:
+		if rt.Target != "xml" {
+			return ProcInst{Target: rt.Target, Inst: bytes.Clone(rt.Inst)}
+		}
+	}
+	return nil
+}
+
+func (p *parser) createElement(tok xml.StartElement) *Element {
+	res := CreateElement(tok.Name)
 	for _, attr := range tok.Attr {
 		res.AddAttr(attr)
 	}
+	if p.opts.Preserve && p.hasRaw() {
+		res.recordSource(tok, p.raw())
+	}
+	if p.opts.Positions {
+		res.pos = &sourceSpan{start: p.tokenStart()}
+	}
+	return res
+}
 
-	for {
-		newtok, err := decoder.Token()
+func (p *parser) text(e *Element, data xml.CharData, ws WhitespacePolicy) {
+	if p.opts.PreserveMarkup && bytes.HasPrefix(p.raw(), []byte("<![CDATA[")) {
+		e.AddNode(CData(data.Copy()))
+		return
+	}
+
+	if p.opts.MixedContent {
+		t := Text(data.Copy())
+		switch {
+		case p.opts.Preserve || ws == PreserveWhitespace:
+			e.AddNode(t)
+		case t.isIndentation():
+			// indentation is dropped
+		case ws == CollapseWhitespace:
+			e.AddNode(Text(collapseSpace(t, false)))
+		default:
+			e.AddNode(t)
+		}
+		return
+	}
+
+	switch ws {
+	case PreserveWhitespace:
+		// blank text is kept unless there is other text; see parseElement
+		if !Text(data).isBlank() || len(e.Content) == 0 {
+			e.Content = data.Copy()
+		}
+	case CollapseWhitespace:
+		if content := collapseSpace(data, true); len(content) > 0 {
+			e.Content = content
+		}
+	default:
+		if content := bytes.TrimSpace(data.Copy()); len(content) > 0 {
+			e.Content = content
+		}
+	}
+}
+
+// parseElement reads the rest of the element that starts with tok.  The
+// whitespace policy is that inherited from its parent.
+func (p *parser) parseElement(tok xml.StartElement, ws WhitespacePolicy) (*Element, error) {
+	res := p.createElement(tok)
+	open := []*Element{res}
+	spaces := []WhitespacePolicy{p.whitespace(res, ws)}
+
+	for len(open) > 0 {
+		newtok, err := p.token()
 		if err != nil {
 			return nil, err
 		}
+		current := open[len(open)-1]
+		ws := spaces[len(spaces)-1]
 		switch rt := newtok.(type) {
+		case xml.Comment, xml.ProcInst:
+			if n := p.markup(rt); n != nil {
+				current.AddNode(n)
+			}
 		case xml.EndElement:
-			return res, nil
+			if current.pos != nil {
+				current.pos.end = p.position()
+			}
+			if ws == PreserveWhitespace && len(current.children) > 0 && Text(current.Content).isBlank() {
+				current.Content = nil // indentation around the children
+			}
+			open = open[:len(open)-1]
+			spaces = spaces[:len(spaces)-1]
 		case xml.CharData:
-			content := bytes.TrimSpace([]byte(rt.Copy()))
-			if len(content) > 0 {
-				res.Content = content
+			p.text(current, rt, ws)
+		case xml.StartElement:
+			child := p.createElement(rt)
+			current.AddChild(child)
+			open = append(open, child)
+			spaces = append(spaces, p.whitespace(child, ws))
+		}
+	}
+
+	return res, nil
+}
+
+func (p *parser) parseElements() (elements []*Element, err error) {
+	elements = []*Element{}
+	for {
+		tok, err := p.token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return elements, err
+		}
+		switch rt := tok.(type) {
+		case xml.StartElement:
+			element, err := p.parseElement(rt, p.opts.Whitespace)
+			if err != nil {
+				return elements, err
 			}
+			elements = append(elements, element)
+		}
+	}
+	return elements, nil
+}
+
+func (p *parser) parseDocument() (doc *Document, err error) {
+	doc = &Document{}
+	if p.opts.Preserve {
+		doc.decl = &declSource{}
+	}
+	for {
+		tok, err := p.token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch rt := tok.(type) {
 		case xml.StartElement:
-			child, err := parseElement(decoder, rt)
+			if doc.root != nil {
+				pe := p.parseErrorAt(fmt.Errorf("%w: found <%s>", TooManyRootElements, rt.Name.Local), p.tokenStart())
+				pe.Path = "" // p.open holds only the extra element itself
+				if p.rec == nil {
+					return nil, pe
+				}
+				// when recovering, the extra element is skipped
+				p.rec.problems = append(p.rec.problems, pe)
+				if _, err := p.parseElement(rt, p.opts.Whitespace); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			root, err := p.parseElement(rt, p.opts.Whitespace)
 			if err != nil {
 				return nil, err
 			}
-			res.AddChild(child)
+			doc.SetRoot(root)
+		case xml.ProcInst:
+			if rt.Target == "xml" {
+				doc.Version = procInstParam(rt.Inst, "version")
+				doc.Encoding = procInstParam(rt.Inst, "encoding")
+				doc.Standalone = procInstParam(rt.Inst, "standalone")
+				if doc.decl != nil {
+					doc.decl = &declSource{raw: p.rawOr("<?xml " + string(rt.Inst) + "?>")}
+					doc.decl.version, doc.decl.encoding, doc.decl.standalone = doc.Version, doc.Encoding, doc.Standalone
+				}
+			} else if n := p.markup(rt); n != nil {
+				doc.addMisc(n)
+			}
+		case xml.Directive:
+			if dt := parseDocType(rt); dt != nil {
+				if p.opts.Preserve {
+					dt.recordSource(p.rawOr("<!" + string(rt) + ">"))
+				}
+				doc.Prolog = append(doc.Prolog, dt)
+			}
+		case xml.CharData:
+			if p.opts.Preserve {
+				doc.addMisc(Text(rt.Copy()))
+			}
+		case xml.Comment:
+			if n := p.markup(rt); n != nil {
+				doc.addMisc(n)
+			}
 		}
 	}
+	return doc, nil
 }
 
+func newStrictDecoder(r io.Reader) *xml.Decoder {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = true
+	return decoder
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// ParseElements strictly parses the XML elements using these options.
+// If the input is malformed, a [*ParseError] is returned.
+func (opts ParseOptions) ParseElements(r io.Reader) (elements []*Element, err error) {
+	return opts.newParser(r).parseElements()
+}
+
+// ParseElementsWithDecoder is like [ParseOptions.ParseElements] but the decoder options can be specified.
+func (opts ParseOptions) ParseElementsWithDecoder(decoder *xml.Decoder) (elements []*Element, err error) {
+	p := &parser{decoder: decoder, opts: opts.normalised()}
+	return p.parseElements()
+}
+
+// Parse strictly parses an XML document using these options and returns a
+// [Document] if input was well-formed. Otherwise, it returns a [*ParseError].
+func (opts ParseOptions) Parse(r io.Reader) (doc *Document, err error) {
+	return opts.newParser(r).parseDocument()
+}
+
+// ParseWithDecoder is like [ParseOptions.Parse] but the decoder options can be specified.
+// To read encodings other than UTF-8, set the CharsetReader of the decoder,
+// e.g. to [CharsetReader].
+func (opts ParseOptions) ParseWithDecoder(decoder *xml.Decoder) (doc *Document, err error) {
+	p := &parser{decoder: decoder, opts: opts.normalised()}
+	return p.parseDocument()
+}
+
+//-------------------------------------------------------------------------------------------------
+
 // ParseElementString strictly parses the XML elements. If the input is malformed,
 // an error is returned.
 //
-// This assumes the input is always UTF-8, no matter what the <?xml?> header says.
+// The character encoding is determined by the byte order mark or the <?xml?>
+// declaration, if there is one, and is otherwise UTF-8; see [CharsetReader].
 func ParseElementString(xml string) (elements []*Element, err error) {
 	return ParseElements(strings.NewReader(xml))
 }
@@ -50,34 +474,15 @@ func ParseElementString(xml string) (elements []*Element, err error) {
 // ParseElements strictly parses the XML elements. If the input is malformed,
 // an error is returned.
 //
-// This assumes the input is always UTF-8, no matter what the <?xml?> header says.
+// The character encoding is determined by the byte order mark or the <?xml?>
+// declaration, if there is one, and is otherwise UTF-8; see [CharsetReader].
 func ParseElements(r io.Reader) (elements []*Element, err error) {
-	decoder := xml.NewDecoder(r)
-	decoder.Strict = true
-	return ParseElementsWithDecoder(decoder)
+	return ParseOptions{}.ParseElements(r)
 }
 
 // ParseElementsWithDecoder is like [ParseElements] but the decoder options can be specified.
 func ParseElementsWithDecoder(decoder *xml.Decoder) (elements []*Element, err error) {
-	elements = []*Element{}
-	for {
-		tok, err := decoder.Token()
-		if err == io.EOF {
-			break
-		}
-		if err != nil {
-			return elements, err
-		}
-		switch rt := tok.(type) {
-		case xml.StartElement:
-			element, err := parseElement(decoder, rt)
-			if err != nil {
-				return elements, err
-			}
-			elements = append(elements, element)
-		}
-	}
-	return elements, nil
+	return ParseOptions{}.ParseElementsWithDecoder(decoder)
 }
 
 // ParseString strictly parses an XML document and returns a [Document] if input was well-formed.
@@ -89,23 +494,10 @@ func ParseString(xml string) (doc *Document, err error) {
 // Parse strictly parses an XML document from a [io.Reader] and returns a [Document] if
 // input was well-formed. Otherwise, it returns an error.
 func Parse(r io.Reader) (doc *Document, err error) {
-	decoder := xml.NewDecoder(r)
-	decoder.Strict = true
-	return ParseWithDecoder(decoder)
+	return ParseOptions{}.Parse(r)
 }
 
 // ParseWithDecoder is like [Parse] but the decoder options can be specified.
 func ParseWithDecoder(decoder *xml.Decoder) (doc *Document, err error) {
-	elements, err := ParseElementsWithDecoder(decoder)
-	if err != nil {
-		return nil, err
-	}
-	if len(elements) > 1 {
-		return nil, TooManyRootElements
-	}
-	doc = CreateDocument()
-	if len(elements) == 1 {
-		doc.SetRoot(elements[0])
-	}
-	return doc, nil
+	return ParseOptions{}.ParseWithDecoder(decoder)
 }
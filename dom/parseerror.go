@@ -0,0 +1,152 @@
+package dom
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// ParseError is returned when input cannot be parsed.  It wraps the
+// underlying error, e.g. an [*xml.SyntaxError], [TooManyRootElements] or a
+// [*LimitError], which can be tested using [errors.Is] and [errors.As].
+type ParseError struct {
+	// Position is where the problem was found.  For syntax errors, this is
+	// the last byte read, which is usually just after the offending markup.
+	Position Position
+
+	// Path is the path of the element that was open at the time, e.g.
+	// "/Envelope/Body/getQuote", or empty if there was none.  It has local
+	// names only, without positions.
+	Path string
+
+	// Snippet is the line of input around the Position, followed by a line
+	// with a "^" marking the Position.  It is empty if the input was not
+	// available, as is the case when parsing with a decoder supplied by the
+	// caller.
+	Snippet string
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *ParseError) Error() string {
+	var msg string
+	var se *xml.SyntaxError
+	var le *LimitError
+	switch {
+	case errors.As(e.Err, &se):
+		msg = "XML syntax error: " + se.Msg
+	case errors.As(e.Err, &le):
+		msg = le.message()
+	default:
+		msg = e.Err.Error()
+	}
+
+	if e.Position.IsValid() {
+		msg = fmt.Sprintf("%s at line %d, column %d", msg, e.Position.Line, e.Position.Column)
+	}
+	if e.Path != "" {
+		msg = msg + " in " + e.Path
+	}
+	return msg
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// parseError wraps err in a ParseError at the current position.  A
+// LimitError knows its own position; otherwise the position is the last
+// byte read by the decoder.
+func (p *parser) parseError(err error) *ParseError {
+	var pos Position
+	var le *LimitError
+	if errors.As(err, &le) && le.Position.IsValid() {
+		pos = le.Position
+	} else {
+		pos = p.position()
+		if pos.Column > 1 {
+			pos.Column--
+			pos.Offset--
+		}
+	}
+	return p.parseErrorAt(err, pos)
+}
+
+func (p *parser) parseErrorAt(err error, pos Position) *ParseError {
+	pe := &ParseError{Position: pos, Err: err}
+	for _, o := range p.open {
+		pe.Path += "/" + o.name.Local
+	}
+	if p.recent != nil {
+		pe.Snippet = p.recent.snippet(pos.Offset)
+	}
+	return pe
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// recentInputSize is how much of the input is kept for error snippets.  It
+// exceeds the read-ahead of the decoder.
+const recentInputSize = 8192
+
+// snippetWidth is the most that a snippet shows either side of the error.
+const snippetWidth = 40
+
+// recentInput keeps the most recent input, so that errors can show where
+// they occurred.
+type recentInput struct {
+	r     io.Reader
+	buf   []byte
+	start int64 // the offset of buf[0]
+}
+
+func (ri *recentInput) Read(b []byte) (int, error) {
+	n, err := ri.r.Read(b)
+	ri.buf = append(ri.buf, b[:n]...)
+	if len(ri.buf) > 2*recentInputSize {
+		drop := len(ri.buf) - recentInputSize
+		ri.buf = ri.buf[:copy(ri.buf, ri.buf[drop:])]
+		ri.start += int64(drop)
+	}
+	return n, err
+}
+
+// snippet returns the line around offset, and a line with a caret under it.
+func (ri *recentInput) snippet(offset int64) string {
+	i := int(offset - ri.start)
+	if i < 0 || i > len(ri.buf) {
+		return ""
+	}
+
+	from := max(0, i-snippetWidth)
+	from += bytes.LastIndexByte(ri.buf[from:i], '\n') + 1
+	for from < i && !utf8.RuneStart(ri.buf[from]) {
+		from++
+	}
+	to := min(len(ri.buf), i+snippetWidth)
+	if nl := bytes.IndexByte(ri.buf[i:to], '\n'); nl >= 0 {
+		to = i + nl
+	}
+	for to > i && to < len(ri.buf) && !utf8.RuneStart(ri.buf[to]) {
+		to--
+	}
+
+	before := strings.ToValidUTF8(string(ri.buf[from:i]), "�")
+	after := strings.ToValidUTF8(strings.TrimRight(string(ri.buf[i:to]), "\r"), "�")
+
+	var caret strings.Builder
+	for _, r := range before {
+		if r == '\t' {
+			caret.WriteByte('\t')
+		} else {
+			caret.WriteByte(' ')
+		}
+	}
+	caret.WriteByte('^')
+	return before + after + "\n" + caret.String()
+}
@@ -0,0 +1,70 @@
+package dom
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestParseError(t *testing.T) {
+	const input = `<Envelope>
+  <Body>
+	<getQuote>IBM</getQuot>
+  </Body>
+</Envelope>`
+
+	_, err := ParseString(input)
+	var pe *ParseError
+	expect.Bool(errors.As(err, &pe)).ToBeTrue(t)
+	var se *xml.SyntaxError
+	expect.Bool(errors.As(err, &se)).ToBeTrue(t)
+
+	expect.Number(pe.Position.Line).ToBe(t, 3)
+	expect.Number(pe.Position.Column).ToBe(t, 24)
+	expect.Number(pe.Position.Offset).ToBe(t, int64(strings.Index(input, "</getQuot>")+9))
+	expect.String(pe.Path).ToBe(t, "/Envelope/Body/getQuote")
+	expect.String(pe.Snippet).ToBe(t, "\t<getQuote>IBM</getQuot>\n\t"+strings.Repeat(" ", 22)+"^")
+	expect.String(err.Error()).ToBe(t,
+		"XML syntax error: element <getQuote> closed by </getQuot> at line 3, column 24 in /Envelope/Body/getQuote")
+}
+
+func TestParseErrorSnippet(t *testing.T) {
+	long := strings.Repeat("x", 100)
+	_, err := ParseString("<a>" + long + "&bad;" + long + "</a>")
+	var pe *ParseError
+	expect.Bool(errors.As(err, &pe)).ToBeTrue(t)
+	expect.String(pe.Path).ToBe(t, "/a")
+	lines := strings.Split(pe.Snippet, "\n")
+	expect.Slice(lines).ToHaveLength(t, 2)
+	expect.Number(len(lines[0])).ToBe(t, 2*snippetWidth)
+	expect.String(lines[1]).ToBe(t, strings.Repeat(" ", snippetWidth)+"^")
+
+	// the snippet is not available when the decoder is supplied
+	_, err = ParseWithDecoder(xml.NewDecoder(strings.NewReader("<a><b></a>")))
+	expect.Bool(errors.As(err, &pe)).ToBeTrue(t)
+	expect.String(pe.Path).ToBe(t, "/a/b")
+	expect.String(pe.Snippet).ToBe(t, "")
+}
+
+func TestParseErrorWrapping(t *testing.T) {
+	_, err := ParseString("<a/>\n<b/>")
+	var pe *ParseError
+	expect.Bool(errors.As(err, &pe)).ToBeTrue(t)
+	expect.Bool(errors.Is(err, TooManyRootElements)).ToBeTrue(t)
+	expect.String(pe.Snippet).ToBe(t, "<b/>\n^")
+	expect.String(pe.Path).ToBe(t, "")
+	expect.String(err.Error()).ToBe(t, "no more than one root element is allowed: found <b> at line 2, column 1")
+
+	_, err = ParseOptions{Limits: Limits{MaxAttrs: 1}}.Parse(strings.NewReader(`<a><b x="1" y="2"/></a>`))
+	expect.Bool(errors.As(err, &pe)).ToBeTrue(t)
+	expect.Bool(errors.Is(err, AttrLimitExceeded)).ToBeTrue(t)
+	expect.String(pe.Snippet).ToBe(t, "<a><b x=\"1\" y=\"2\"/></a>\n   ^")
+
+	// errors from the stream callback are not wrapped
+	failed := errors.New("failed")
+	err = Stream(strings.NewReader(`<a><b/></a>`), func(*Element) bool { return true }, func(*Element) error { return failed })
+	expect.Bool(err == failed).ToBeTrue(t)
+}
@@ -0,0 +1,216 @@
+package dom
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	InvalidPath   = errors.New("invalid path")
+	PathNotFound  = errors.New("no element at path")
+	AmbiguousPath = errors.New("path matches more than one element")
+)
+
+// Path returns the location of node as an XPath-like path, e.g.
+// "/Envelope[1]/Body[1]/item[3]".  Each step has the local name of the
+// element and its 1-based position amongst its siblings of the same name.
+// The first step is the topmost ancestor, which is usually the document root.
+//
+// Elements whose names differ only by namespace are not distinguished by
+// Path; use [Element.QualifiedPath] when that matters.
+func (node *Element) Path() string {
+	return node.path(func(n xml.Name) string { return n.Local })
+}
+
+// QualifiedPath is like [Element.Path] but each name is in Clark notation,
+// i.e. "{uri}local", so that the path does not depend on the namespace
+// prefixes in use, e.g. "/{urn:s}Envelope[1]/{urn:s}Body[1]/item[3]".
+func (node *Element) QualifiedPath() string {
+	return node.path(formatName)
+}
+
+func (node *Element) path(name func(xml.Name) string) string {
+	var steps []string
+	for e := node; e != nil; e = e.parent {
+		steps = append(steps, name(e.Name)+"["+strconv.Itoa(e.positionByName())+"]")
+	}
+	var b strings.Builder
+	for i := len(steps) - 1; i >= 0; i-- {
+		b.WriteByte('/')
+		b.WriteString(steps[i])
+	}
+	return b.String()
+}
+
+// positionByName returns the 1-based position of node amongst its siblings with the same name.
+func (node *Element) positionByName() int {
+	if node.parent == nil {
+		return 1
+	}
+	n := 1
+	for _, c := range node.parent.children[:node.index] {
+		if c.Name == node.Name {
+			n++
+		}
+	}
+	return n
+}
+
+// Resolve finds the element at a path as returned by [Element.Path] or
+// [Element.QualifiedPath]; a missing position is taken to be [1].  A step
+// without a namespace matches an element in no namespace if there is one,
+// otherwise it matches by local name alone and [AmbiguousPath] is returned
+// if elements in different namespaces would match.  If there is no such
+// element, the error is [PathNotFound].
+func (doc *Document) Resolve(path string) (*Element, error) {
+	steps, err := splitPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := []*Element{}
+	if doc.root != nil {
+		candidates = append(candidates, doc.root)
+	}
+	var found *Element
+	for i, s := range steps {
+		found, err = s.find(candidates)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", err, "/"+strings.Join(rawSteps(steps[:i+1]), "/"))
+		}
+		candidates = found.children
+	}
+	return found, nil
+}
+
+type pathStep struct {
+	raw       string
+	name      xml.Name
+	qualified bool
+	position  int
+}
+
+// splitPath splits a path into steps, allowing for "/" within "{uri}".
+func splitPath(path string) ([]pathStep, error) {
+	if !strings.HasPrefix(path, "/") || len(path) < 2 {
+		return nil, fmt.Errorf("%w: %q", InvalidPath, path)
+	}
+
+	var steps []pathStep
+	rest := path[1:]
+	for rest != "" {
+		end := len(rest)
+		depth := 0
+	scan:
+		for i := 0; i < len(rest); i++ {
+			switch {
+			case rest[i] == '{':
+				depth++
+			case rest[i] == '}':
+				depth--
+			case rest[i] == '/' && depth == 0:
+				end = i
+				break scan
+			}
+		}
+
+		s, err := parseStep(rest[:end])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", InvalidPath, path)
+		}
+		steps = append(steps, s)
+
+		rest = rest[end:]
+		if rest != "" {
+			rest = rest[1:]
+			if rest == "" {
+				return nil, fmt.Errorf("%w: %q", InvalidPath, path)
+			}
+		}
+	}
+	return steps, nil
+}
+
+func parseStep(raw string) (pathStep, error) {
+	s := pathStep{raw: raw, position: 1}
+	name := raw
+	if i := strings.LastIndexByte(raw, '['); i > strings.LastIndexByte(raw, '}') {
+		pos, found := strings.CutSuffix(raw[i+1:], "]")
+		n, err := strconv.Atoi(pos)
+		if !found || err != nil || n < 1 {
+			return s, InvalidPath
+		}
+		s.position = n
+		name = raw[:i]
+	}
+
+	if strings.HasPrefix(name, "{") {
+		end := strings.IndexByte(name, '}')
+		if end < 0 {
+			return s, InvalidPath
+		}
+		s.name = xml.Name{Space: name[1:end], Local: name[end+1:]}
+		s.qualified = true
+	} else {
+		s.name = xml.Name{Local: name}
+	}
+
+	if s.name.Local == "" {
+		return s, InvalidPath
+	}
+	return s, nil
+}
+
+// find picks the step's element from the candidates.  An unqualified name
+// matches an element in no namespace if there is one, otherwise it matches
+// by local name alone.
+func (s pathStep) find(candidates []*Element) (*Element, error) {
+	if found := s.findExact(candidates); found != nil || s.qualified {
+		if found == nil {
+			return nil, PathNotFound
+		}
+		return found, nil
+	}
+
+	var found *Element
+	counts := make(map[xml.Name]int)
+	for _, c := range candidates {
+		if c.Name.Local == s.name.Local {
+			counts[c.Name]++
+			if counts[c.Name] == s.position {
+				if found != nil {
+					return nil, AmbiguousPath
+				}
+				found = c
+			}
+		}
+	}
+	if found == nil {
+		return nil, PathNotFound
+	}
+	return found, nil
+}
+
+func (s pathStep) findExact(candidates []*Element) *Element {
+	n := 0
+	for _, c := range candidates {
+		if c.Name == s.name {
+			n++
+			if n == s.position {
+				return c
+			}
+		}
+	}
+	return nil
+}
+
+func rawSteps(steps []pathStep) []string {
+	res := make([]string, len(steps))
+	for i, s := range steps {
+		res[i] = s.raw
+	}
+	return res
+}
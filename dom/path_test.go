@@ -0,0 +1,68 @@
+package dom
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+const pathDoc = `<s:Envelope xmlns:s="urn:s" xmlns:x="urn:x/1"><s:Body><item/><x:item/><item/><x:item><c/></x:item></s:Body></s:Envelope>`
+
+func TestPath(t *testing.T) {
+	doc, err := ParseString(pathDoc)
+	expect.Error(err).ToBeNil(t)
+	items := doc.Root().ChildAt(0).Children()
+
+	expect.String(doc.Root().Path()).ToBe(t, "/Envelope[1]")
+	expect.String(items[2].Path()).ToBe(t, "/Envelope[1]/Body[1]/item[2]")
+	expect.String(items[3].Path()).ToBe(t, "/Envelope[1]/Body[1]/item[2]")
+	expect.String(items[3].ChildAt(0).QualifiedPath()).ToBe(t, "/{urn:s}Envelope[1]/{urn:s}Body[1]/{urn:x/1}item[2]/c[1]")
+
+	// detached elements have paths relative to their topmost ancestor
+	expect.String(Elem("a", "").AddChild(Elem("b", "")).ChildAt(0).Path()).ToBe(t, "/a[1]/b[1]")
+}
+
+func TestResolve(t *testing.T) {
+	doc, err := ParseString(pathDoc)
+	expect.Error(err).ToBeNil(t)
+
+	for _, e := range doc.Root().All() {
+		found, err := doc.Resolve(e.QualifiedPath())
+		expect.Error(err).ToBeNil(t)
+		expect.Bool(found == e).I(e.QualifiedPath()).ToBeTrue(t)
+	}
+
+	c, err := doc.Resolve("/Envelope/Body/{urn:x/1}item[2]/c")
+	expect.Error(err).ToBeNil(t)
+	expect.String(c.Name.Local).ToBe(t, "c")
+
+	// unqualified names prefer elements in no namespace
+	item, err := doc.Resolve("/Envelope/Body/item[2]")
+	expect.Error(err).ToBeNil(t)
+	expect.Bool(item == doc.Root().ChildAt(0).ChildAt(2)).ToBeTrue(t)
+
+	_, err = doc.Resolve("/Envelope/Body/item[3]")
+	expect.Bool(errors.Is(err, PathNotFound)).ToBeTrue(t)
+	expect.Error(err).ToContain(t, "/Envelope/Body/item[3]")
+
+	_, err = doc.Resolve("/Envelope/Body/{urn:x/1}item[1]/c")
+	expect.Bool(errors.Is(err, PathNotFound)).ToBeTrue(t)
+
+	for _, bad := range []string{"", "/", "Envelope", "/Envelope//Body", "/Envelope/", "/Envelope[0]", "/{urn:s/Envelope"} {
+		_, err = doc.Resolve(bad)
+		expect.Bool(errors.Is(err, InvalidPath)).I(bad).ToBeTrue(t)
+	}
+}
+
+func TestResolveAmbiguous(t *testing.T) {
+	doc, err := ParseString(`<a xmlns:x="urn:x" xmlns:y="urn:y"><x:b/><y:b/></a>`)
+	expect.Error(err).ToBeNil(t)
+
+	_, err = doc.Resolve("/a/b")
+	expect.Bool(errors.Is(err, AmbiguousPath)).ToBeTrue(t)
+
+	b, err := doc.Resolve("/a/{urn:y}b")
+	expect.Error(err).ToBeNil(t)
+	expect.String(b.Name.Space).ToBe(t, "urn:y")
+}
@@ -0,0 +1,64 @@
+package dom
+
+import "fmt"
+
+// Position is a location in the input from which an element was parsed.
+//
+// Column and Offset count bytes of the input as seen by the XML decoder,
+// which is UTF-8.  So for input in another charset, such as UTF-16 or
+// ISO-8859-1, they count the bytes after conversion to UTF-8, and a byte
+// order mark at the start of the input is not counted.
+type Position struct {
+	// Line and Column are 1-based; Column counts bytes.
+	Line, Column int
+	// Offset is the 0-based byte offset from the start of the input, after
+	// any conversion to UTF-8.
+	Offset int64
+}
+
+// IsValid returns true if the position was recorded.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// sourceSpan is where an element was found in the input.
+type sourceSpan struct {
+	start, end Position
+}
+
+// Position returns the position of the start tag of node, i.e. of its "<".
+// Positions are only recorded when parsing with [ParseOptions] Positions;
+// otherwise the result is not valid.
+func (node *Element) Position() Position {
+	if node.pos == nil {
+		return Position{}
+	}
+	return node.pos.start
+}
+
+// EndPosition returns the position immediately after the end tag of node,
+// or after "/>" if it is an empty-element tag.  As with [Element.Position],
+// the result is only valid when positions were recorded.
+func (node *Element) EndPosition() Position {
+	if node.pos == nil {
+		return Position{}
+	}
+	return node.pos.end
+}
+
+// Location describes where node is, for use in messages.  It is the
+// [Element.Path] of node, followed by its line and column if they were
+// recorded, e.g. "/Envelope[1]/Body[1] (line 3, column 5)".
+func (node *Element) Location() string {
+	if p := node.Position(); p.IsValid() {
+		return fmt.Sprintf("%s (line %d, column %d)", node.Path(), p.Line, p.Column)
+	}
+	return node.Path()
+}
@@ -0,0 +1,58 @@
+package dom
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+const positionedDoc = `<?xml version="1.0"?>
+<a>
+  <b x="1">text</b>
+  <c/>
+</a>`
+
+func TestPositions(t *testing.T) {
+	doc, err := ParseOptions{Positions: true}.Parse(strings.NewReader(positionedDoc))
+	expect.Error(err).ToBeNil(t)
+
+	a := doc.Root()
+	b, c := a.ChildAt(0), a.ChildAt(1)
+
+	expect.Any(a.Position()).ToBe(t, Position{Line: 2, Column: 1, Offset: 22})
+	expect.Any(a.EndPosition()).ToBe(t, Position{Line: 5, Column: 5, Offset: int64(len(positionedDoc))})
+	expect.Any(b.Position()).ToBe(t, Position{Line: 3, Column: 3, Offset: 28})
+	expect.String(positionedDoc[b.Position().Offset:b.EndPosition().Offset]).ToBe(t, `<b x="1">text</b>`)
+	expect.String(positionedDoc[c.Position().Offset:c.EndPosition().Offset]).ToBe(t, `<c/>`)
+
+	expect.String(b.Position().String()).ToBe(t, "3:3")
+	expect.String(c.Location()).ToBe(t, "/a[1]/c[1] (line 4, column 3)")
+	expect.Any(c.Clone().Position()).ToBe(t, c.Position())
+
+	_, err = b.AttrBool("x", "")
+	expect.Error(err).ToBeNil(t)
+	_, err = b.ContentInt()
+	expect.Error(err).ToContain(t, "/a[1]/b[1] (line 3, column 3): content: ")
+
+	other, err := ParseOptions{Positions: true}.Parse(strings.NewReader(strings.Replace(positionedDoc, "text", "other", 1)))
+	expect.Error(err).ToBeNil(t)
+	_, m := Equal(a, other.Root())
+	expect.String(m.Error()).ToBe(t, `/a[1]/b[1] (line 3, column 3): content "text" differs from "other"`)
+}
+
+func TestNoPositions(t *testing.T) {
+	doc, err := ParseString(positionedDoc)
+	expect.Error(err).ToBeNil(t)
+	c := doc.Root().ChildAt(1)
+	expect.Bool(c.Position().IsValid()).ToBeFalse(t)
+	expect.String(c.Position().String()).ToBe(t, "-")
+	expect.String(c.Location()).ToBe(t, "/a[1]/c[1]")
+}
+
+func TestTooManyRootElementsPosition(t *testing.T) {
+	_, err := ParseString("<a/>\n <b/>")
+	expect.Bool(errors.Is(err, TooManyRootElements)).ToBeTrue(t)
+	expect.Error(err).ToContain(t, "found <b> at line 2, column 2")
+}
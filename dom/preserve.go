@@ -0,0 +1,307 @@
+package dom
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const xmlURL = "http://www.w3.org/XML/1998/namespace"
+
+// elementSource records how an element was written in the input, so that a
+// preserving [Encoder] can write it the same way.
+type elementSource struct {
+	prefix    string
+	attrs     map[xml.Name]attrSource
+	tail      string // whitespace before ">" or "/>"
+	selfClose bool
+}
+
+type attrSource struct {
+	space  string // whitespace before the attribute
+	prefix string
+	quote  byte
+}
+
+// lexStartTag reads the layout of a start tag, e.g. `<a:b c='1' d="2"/>`.  The
+// attributes are in the same order as those in the corresponding [xml.StartElement].
+func lexStartTag(raw []byte) (src *elementSource, attrs []attrSource) {
+	s := strings.TrimPrefix(string(raw), "<")
+	src = &elementSource{}
+
+	end := strings.IndexAny(s, " \t\r\n/>")
+	if end < 0 {
+		return nil, nil
+	}
+	src.prefix, _, _ = cutPrefix(s[:end])
+	s = s[end:]
+
+	for {
+		rest := strings.TrimLeft(s, " \t\r\n")
+		space := s[:len(s)-len(rest)]
+		if rest == "" || rest[0] == '/' || rest[0] == '>' {
+			src.tail = space
+			src.selfClose = strings.HasPrefix(rest, "/")
+			return src, attrs
+		}
+
+		name, value, found := strings.Cut(rest, "=")
+		if !found {
+			return nil, nil
+		}
+		value = strings.TrimLeft(value, " \t\r\n")
+		if value == "" {
+			return nil, nil
+		}
+		q := value[0]
+		end := strings.IndexByte(value[1:], q)
+		if end < 0 {
+			return nil, nil
+		}
+		prefix, _, _ := cutPrefix(strings.TrimSpace(name))
+		attrs = append(attrs, attrSource{space: space, prefix: prefix, quote: q})
+		s = value[end+2:]
+	}
+}
+
+// cutPrefix splits a qualified name into its prefix and local part.
+func cutPrefix(qname string) (prefix, local string, found bool) {
+	prefix, local, found = strings.Cut(qname, ":")
+	if !found {
+		return "", qname, false
+	}
+	return prefix, local, true
+}
+
+// recordSource attaches the layout of the start tag to e.
+func (e *Element) recordSource(tok xml.StartElement, raw []byte) {
+	src, attrs := lexStartTag(raw)
+	if src == nil {
+		return
+	}
+	if len(attrs) == len(tok.Attr) {
+		src.attrs = make(map[xml.Name]attrSource, len(attrs))
+		for i, a := range tok.Attr {
+			src.attrs[a.Name] = attrs[i]
+		}
+	}
+	e.src = src
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// isNamespaceDecl returns true for xmlns="..." and xmlns:p="..." attributes.
+func isNamespaceDecl(a xml.Attr) bool {
+	return a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns")
+}
+
+// declaredPrefix returns the prefix declared by an xmlns attribute; it is
+// empty for the default namespace.
+func declaredPrefix(a xml.Attr) string {
+	if a.Name.Space == "xmlns" {
+		return a.Name.Local
+	}
+	return ""
+}
+
+// scope holds the namespace declarations in force while a preserving
+// [Encoder] writes an element.
+type scope struct {
+	parent   *scope
+	prefixes map[string]string // prefix to URI; "" is the default namespace
+	added    []string          // prefixes declared by the encoder, not by attributes
+}
+
+func (s *scope) lookup(prefix string) (string, bool) {
+	for ; s != nil; s = s.parent {
+		if uri, found := s.prefixes[prefix]; found {
+			return uri, true
+		}
+	}
+	return "", false
+}
+
+// prefixFor finds a prefix in scope that is bound to uri.
+func (s *scope) prefixFor(uri string, isAttr bool) (string, bool) {
+	for t := s; t != nil; t = t.parent {
+		for _, p := range slices.Sorted(maps.Keys(t.prefixes)) {
+			if (p != "" || !isAttr) && t.prefixes[p] == uri {
+				if bound, _ := s.lookup(p); bound == uri {
+					return p, true
+				}
+			}
+		}
+	}
+	return "", false
+}
+
+// qualify returns the qualified name to write for name, declaring its namespace if necessary.
+func (s *scope) qualify(e *Encoder, name xml.Name, preferred string, known, isAttr bool) string {
+	switch name.Space {
+	case "":
+		if uri, _ := s.lookup(""); uri != "" && !isAttr {
+			s.declare("", "")
+		}
+		return name.Local
+	case xmlURL:
+		return "xml:" + name.Local
+	}
+
+	if known && (preferred != "" || !isAttr) {
+		if uri, _ := s.lookup(preferred); uri == name.Space {
+			return qualifiedName(preferred, name.Local)
+		}
+	}
+
+	if p, found := s.prefixFor(name.Space, isAttr); found {
+		return qualifiedName(p, name.Local)
+	}
+
+	prefix := preferred
+	if _, clash := s.prefixes[prefix]; !known || clash || (prefix == "" && isAttr) {
+		prefix = e.unusedPrefix(s)
+	}
+	s.declare(prefix, name.Space)
+	return qualifiedName(prefix, name.Local)
+}
+
+// declare adds a namespace declaration that was not in the element's attributes.
+func (s *scope) declare(prefix, uri string) {
+	s.prefixes[prefix] = uri
+	s.added = append(s.added, prefix)
+}
+
+// writeAdded writes the namespace declarations added by the encoder.
+func (s *scope) writeAdded(e *Encoder) {
+	for _, prefix := range s.added {
+		if prefix == "" {
+			_, _ = e.WriteString(` xmlns="`)
+		} else {
+			_, _ = fmt.Fprintf(e, ` xmlns:%s="`, prefix)
+		}
+		_ = escapeAttr(e, s.prefixes[prefix], '"')
+		_, _ = e.WriteString(`"`)
+	}
+}
+
+func qualifiedName(prefix, local string) string {
+	if prefix == "" {
+		return local
+	}
+	return prefix + ":" + local
+}
+
+// preservingStartTag writes the start tag of node, other than its closing
+// ">", in the same way as it was parsed.  The qualified name is returned.
+func (node *Element) preservingStartTag(e *Encoder) string {
+	src := node.src
+	if src == nil {
+		src = &elementSource{}
+	}
+
+	s := &scope{parent: e.scope, prefixes: make(map[string]string)}
+	for _, a := range node.Attributes {
+		if isNamespaceDecl(a) {
+			s.prefixes[declaredPrefix(a)] = a.Value
+		}
+	}
+	e.scope = s
+
+	qname := s.qualify(e, node.Name, src.prefix, node.src != nil, false)
+	_, _ = e.WriteString("<")
+	_, _ = e.WriteString(qname)
+
+	for _, a := range node.Attributes {
+		as, known := src.attrs[a.Name]
+		if as.space == "" {
+			as.space = " "
+		}
+		if as.quote == 0 {
+			as.quote = '"'
+		}
+		_, _ = e.WriteString(as.space)
+		if isNamespaceDecl(a) {
+			_, _ = e.WriteString(qualifiedName(a.Name.Space, a.Name.Local))
+		} else {
+			_, _ = e.WriteString(s.qualify(e, a.Name, as.prefix, known, true))
+		}
+		_, _ = e.WriteString("=")
+		_ = e.WriteByte(as.quote)
+		_ = escapeAttr(e, a.Value, as.quote)
+		_ = e.WriteByte(as.quote)
+	}
+
+	s.writeAdded(e)
+	_, _ = e.WriteString(src.tail)
+	return qname
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// escapeAttr writes an attribute value that is delimited by quote.
+func escapeAttr(w io.Writer, s string, quote byte) error {
+	return escape(w, s, func(r rune) string {
+		switch r {
+		case '&':
+			return "&amp;"
+		case '<':
+			return "&lt;"
+		case '"':
+			if quote == '"' {
+				return "&quot;"
+			}
+		case '\'':
+			if quote == '\'' {
+				return "&apos;"
+			}
+		case '\t':
+			return "&#x9;"
+		case '\n':
+			return "&#xA;"
+		case '\r':
+			return "&#xD;"
+		}
+		return ""
+	})
+}
+
+// escapeMinimal writes text, escaping only what is necessary so that
+// whitespace is unchanged.
+func escapeMinimal(w io.Writer, s string) error {
+	return escape(w, s, func(r rune) string {
+		switch r {
+		case '&':
+			return "&amp;"
+		case '<':
+			return "&lt;"
+		case '>':
+			return "&gt;"
+		case '\r':
+			return "&#xD;"
+		}
+		return ""
+	})
+}
+
+func escape(w io.Writer, s string, replacement func(rune) string) error {
+	last := 0
+	for i := 0; i < len(s); {
+		r, width := utf8.DecodeRuneInString(s[i:])
+		i += width
+		if esc := replacement(r); esc != "" {
+			if _, err := io.WriteString(w, s[last:i-width]); err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, esc); err != nil {
+				return err
+			}
+			last = i
+		}
+	}
+	_, err := io.WriteString(w, s[last:])
+	return err
+}
@@ -0,0 +1,71 @@
+package dom
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+const preservedDoc = `<?xml version='1.0' encoding='UTF-8'?>
+<!DOCTYPE Envelope>
+<!-- header -->
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"
+            xmlns:a='urn:a'>
+  <s:Body a:x='1' b="2" xml:lang="en">
+    <a:item></a:item>
+    <item xmlns="urn:b"   y="it's" />
+    <p>Hello <b>big</b>  world &amp; more</p>
+    <q><![CDATA[<raw>]]></q>
+  </s:Body>
+</s:Envelope>
+`
+
+func parsePreserved(t *testing.T, input string) *Document {
+	t.Helper()
+	doc, err := ParseOptions{Preserve: true}.Parse(strings.NewReader(input))
+	expect.Error(err).ToBeNil(t)
+	return doc
+}
+
+func encodePreserved(t *testing.T, doc *Document) string {
+	t.Helper()
+	var sb strings.Builder
+	err := doc.Encode(NewEncoder(&sb, "  ").SetPreserve(true))
+	expect.Error(err).ToBeNil(t)
+	return sb.String()
+}
+
+func TestPreserveRoundTrip(t *testing.T) {
+	doc := parsePreserved(t, preservedDoc)
+	expect.String(encodePreserved(t, doc)).ToBe(t, preservedDoc)
+}
+
+func TestPreserveNoDeclaration(t *testing.T) {
+	const input = "<a>\n\t<b c = 'd'/>\n</a>"
+	doc := parsePreserved(t, input)
+	expect.String(encodePreserved(t, doc)).ToBe(t, "<a>\n\t<b c='d'/>\n</a>")
+}
+
+func TestPreserveAddedElements(t *testing.T) {
+	doc := parsePreserved(t, preservedDoc)
+	body := doc.Root().Children()[0]
+
+	body.AddChild(Elem("new", "urn:a").Attr("z", "urn:c", "3"))
+	body.AddChild(Elem("plain", ""))
+	body.Children()[1].AddChild(Elem("inner", ""))
+
+	out := encodePreserved(t, doc)
+	expect.String(out).ToContain(t, `<a:new ns0:z="3" xmlns:ns0="urn:c"/>`)
+	expect.String(out).ToContain(t, `<item xmlns="urn:b"   y="it's" ><inner xmlns=""/></item>`)
+	expect.String(out).ToContain(t, `<plain/>`)
+
+	reparsed, err := ParseString(out)
+	expect.Error(err).ToBeNil(t)
+	expect.String(reparsed.Root().Children()[0].Children()[4].Attributes[0].Name.Space).ToBe(t, "urn:c")
+}
+
+func TestDefaultEncoderOnPreservedDoc(t *testing.T) {
+	doc := parsePreserved(t, `<a:x xmlns:a="urn:a" xml:lang="en" q='"'/>`)
+	expect.String(doc.Root().Bytes()).ToEqual(t, `<a:x xml:lang="en" q="&quot;" xmlns:a="urn:a"/>`)
+}
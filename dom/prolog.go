@@ -0,0 +1,163 @@
+package dom
+
+import (
+	"bytes"
+	"strings"
+)
+
+// DocType is the document type declaration of a [Document], e.g.
+//
+//	<!DOCTYPE html PUBLIC "-//W3C//DTD XHTML 1.0 Strict//EN" "xhtml1-strict.dtd">
+//
+// It is held in the Prolog of the [Document].
+type DocType struct {
+	Name     string
+	PublicID string
+	SystemID string
+	// InternalSubset holds the declarations between "[" and "]", verbatim.
+	InternalSubset string
+	// raw is set when the DOCTYPE was parsed with Preserve; it is written
+	// as long as the fields have not been changed.
+	raw    string
+	parsed [4]string
+}
+
+func (dt *DocType) fields() [4]string {
+	return [4]string{dt.Name, dt.PublicID, dt.SystemID, dt.InternalSubset}
+}
+
+func (dt *DocType) recordSource(raw string) {
+	dt.raw = raw
+	dt.parsed = dt.fields()
+}
+
+func (dt *DocType) encode(e *Encoder) error {
+	if e.preserve && dt.raw != "" && dt.parsed == dt.fields() {
+		_, _ = e.WriteString(dt.raw)
+		return nil
+	}
+
+	e.spaces()
+	_, _ = e.WriteString("<!DOCTYPE ")
+	_, _ = e.WriteString(dt.Name)
+	if dt.PublicID != "" {
+		_, _ = e.WriteString(" PUBLIC ")
+		writeQuoted(e, dt.PublicID)
+		_, _ = e.WriteString(" ")
+		writeQuoted(e, dt.SystemID)
+	} else if dt.SystemID != "" {
+		_, _ = e.WriteString(" SYSTEM ")
+		writeQuoted(e, dt.SystemID)
+	}
+	if dt.InternalSubset != "" {
+		_, _ = e.WriteString(" [")
+		_, _ = e.WriteString(dt.InternalSubset)
+		_, _ = e.WriteString("]")
+	}
+	_, _ = e.WriteString(">")
+	e.prettyEnd()
+	return nil
+}
+
+// writeQuoted writes a literal, using single quotes only if it contains a double quote.
+func writeQuoted(e *Encoder, s string) {
+	q := `"`
+	if strings.Contains(s, q) {
+		q = `'`
+	}
+	_, _ = e.WriteString(q)
+	_, _ = e.WriteString(s)
+	_, _ = e.WriteString(q)
+}
+
+// parseDocType parses the content of a <!DOCTYPE ...> directive.  If the
+// directive is not a DOCTYPE, nil is returned.
+func parseDocType(directive []byte) *DocType {
+	s, found := strings.CutPrefix(strings.TrimSpace(string(directive)), "DOCTYPE")
+	if !found {
+		return nil
+	}
+
+	dt := &DocType{}
+	if i := strings.IndexByte(s, '['); i >= 0 {
+		if j := strings.LastIndexByte(s, ']'); j > i {
+			dt.InternalSubset = s[i+1 : j]
+		}
+		s = s[:i]
+	}
+
+	fields := literalFields(s)
+	if len(fields) > 0 {
+		dt.Name = fields[0]
+	}
+	if len(fields) > 2 && fields[1] == "PUBLIC" {
+		dt.PublicID = fields[2]
+		if len(fields) > 3 {
+			dt.SystemID = fields[3]
+		}
+	} else if len(fields) > 2 && fields[1] == "SYSTEM" {
+		dt.SystemID = fields[2]
+	}
+	return dt
+}
+
+// literalFields splits s around whitespace, treating quoted literals as
+// single fields without their quotes.
+func literalFields(s string) []string {
+	var fields []string
+	for {
+		s = strings.TrimLeft(s, " \t\r\n")
+		if s == "" {
+			return fields
+		}
+		if q := s[0]; q == '"' || q == '\'' {
+			end := strings.IndexByte(s[1:], q)
+			if end < 0 {
+				return append(fields, s[1:])
+			}
+			fields = append(fields, s[1:end+1])
+			s = s[end+2:]
+		} else {
+			end := strings.IndexAny(s, " \t\r\n")
+			if end < 0 {
+				return append(fields, s)
+			}
+			fields = append(fields, s[:end])
+			s = s[end:]
+		}
+	}
+}
+
+// procInstParam returns the value of a pseudo-attribute in the <?xml ...?>
+// declaration, e.g. encoding="UTF-8".
+func procInstParam(inst []byte, param string) string {
+	for _, f := range literalPairs(inst) {
+		if f[0] == param {
+			return f[1]
+		}
+	}
+	return ""
+}
+
+// literalPairs splits "a='1' b="2"" into name/value pairs.
+func literalPairs(inst []byte) [][2]string {
+	var pairs [][2]string
+	s := string(bytes.TrimSpace(inst))
+	for s != "" {
+		name, rest, found := strings.Cut(s, "=")
+		if !found {
+			break
+		}
+		rest = strings.TrimLeft(rest, " \t\r\n")
+		if rest == "" || (rest[0] != '"' && rest[0] != '\'') {
+			break
+		}
+		end := strings.IndexByte(rest[1:], rest[0])
+		if end < 0 {
+			break
+		}
+		pairs = append(pairs, [2]string{strings.TrimSpace(name), rest[1 : end+1]})
+		s = strings.TrimLeft(rest[end+2:], " \t\r\n")
+	}
+	return pairs
+}
@@ -0,0 +1,61 @@
+package dom
+
+import (
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestPrologRoundTrip(t *testing.T) {
+	const input = `<?xml version="1.0" encoding="utf-8" standalone="yes"?>
+<!DOCTYPE note PUBLIC "-//Example//DTD Note//EN" "note.dtd" [<!ENTITY writer "Donald">]>
+<note/>
+`
+	doc, err := ParseString(input)
+	expect.Error(err).ToBeNil(t)
+
+	expect.String(doc.Version).ToBe(t, "1.0")
+	expect.String(doc.Encoding).ToBe(t, "utf-8")
+	expect.String(doc.Standalone).ToBe(t, "yes")
+	expect.Any(doc.DocType()).ToBe(t, &DocType{
+		Name:           "note",
+		PublicID:       "-//Example//DTD Note//EN",
+		SystemID:       "note.dtd",
+		InternalSubset: `<!ENTITY writer "Donald">`,
+	})
+
+	expect.String(doc.String()).ToBe(t, input)
+}
+
+func TestPrologSystemDocType(t *testing.T) {
+	doc, err := ParseString(`<?xml version='1.0'?><!DOCTYPE a SYSTEM 'a.dtd'><a/>`)
+	expect.Error(err).ToBeNil(t)
+
+	expect.String(doc.Encoding).ToBe(t, "")
+	expect.Any(doc.DocType()).ToBe(t, &DocType{Name: "a", SystemID: "a.dtd"})
+	expect.String(doc.Bytes()).ToEqual(t, `<?xml version="1.0"?><!DOCTYPE a SYSTEM "a.dtd"><a/>`)
+}
+
+func TestPrologDefaults(t *testing.T) {
+	doc, err := ParseString(`<a/>`)
+	expect.Error(err).ToBeNil(t)
+	expect.String(doc.Version).ToBe(t, "")
+	expect.Any(doc.DocType()).ToBeNil(t)
+	expect.String(doc.Bytes()).ToEqual(t, `<?xml version="1.0" encoding="UTF-8"?><a/>`)
+
+	doc = CreateDocument()
+	doc.Encoding = "ISO-8859-1"
+	doc.SetRoot(Elem("a", ""))
+	expect.String(doc.Bytes()).ToEqual(t, `<?xml version="1.0" encoding="UTF-8"?><a/>`)
+}
+
+func TestSetDocType(t *testing.T) {
+	doc := CreateDocument()
+	doc.Prolog = []Node{Comment("c")}
+	doc.SetDocType(&DocType{Name: "a"})
+	expect.Slice(doc.Prolog).ToHaveLength(t, 2)
+	doc.SetDocType(&DocType{Name: "b"})
+	expect.String(doc.DocType().Name).ToBe(t, "b")
+	doc.SetDocType(nil)
+	expect.Slice(doc.Prolog).ToBe(t, Comment("c"))
+}
@@ -0,0 +1,411 @@
+package dom
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	// InvalidCharacter is reported by [ParseOptions.ParseRecover] for each
+	// character that is not allowed in XML, which is skipped.
+	InvalidCharacter = errors.New("invalid character")
+
+	// InvalidReference is reported by [ParseOptions.ParseRecover] for each "&"
+	// that does not start a character reference or one of the predefined
+	// entity references; it is kept as text.
+	InvalidReference = errors.New("invalid character or entity reference")
+
+	// NoRootElement is reported by [ParseOptions.ParseRecover] when the input
+	// has no root element.
+	NoRootElement = errors.New("no root element")
+)
+
+// ParseRecover parses an XML document like [ParseOptions.Parse], but carries
+// on after errors, so that as much of the document as possible is recovered.
+// It returns the document, which may be empty but is never nil, and every
+// problem found, in the order they occur in the input.
+//
+// Characters that are not allowed in XML are skipped, and a "&" that does
+// not start a reference, or a "<" within an attribute value, is kept as
+// text.  Elements that are not closed by the end of the input, or whose end
+// tag is missing before the end tag of an ancestor, are closed
+// automatically.  End tags that match no open element are skipped, as is
+// the rest of any other markup that cannot be parsed; and so are any
+// elements after the root element.  Exceeding one of the [Limits] ends
+// parsing, as does an error reading the input.  If no root element is found,
+// [NoRootElement] is reported.
+//
+// The whole input is read into memory first.
+func (opts ParseOptions) ParseRecover(r io.Reader) (*Document, []*ParseError) {
+	p := opts.newRecoveringParser(r)
+	doc, err := p.parseDocument()
+	if err != nil {
+		// not expected, because errors are recovered from
+		p.rec.problems = append(p.rec.problems, &ParseError{Err: err})
+	}
+	if doc == nil {
+		doc = &Document{}
+	}
+	if doc.root == nil {
+		end := advance(Position{Line: 1, Column: 1}, p.rec.input)
+		p.rec.problems = append(p.rec.problems, p.parseErrorAt(NoRootElement, p.rec.original(end)))
+	}
+	slices.SortStableFunc(p.rec.problems, func(a, b *ParseError) int {
+		return cmp.Compare(a.Position.Offset, b.Position.Offset)
+	})
+	return doc, p.rec.problems
+}
+
+// ParseRecover is like [ParseOptions.ParseRecover] with the default options.
+func ParseRecover(r io.Reader) (*Document, []*ParseError) {
+	return ParseOptions{}.ParseRecover(r)
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// openElement is an element whose end tag has not been read yet.
+type openElement struct {
+	name  xml.Name
+	qname string     // as written in the input; only when recovering
+	ns    []xml.Attr // its namespace declarations; only when recovering or limiting name lengths
+}
+
+func (p *parser) openElement(tok xml.StartElement) openElement {
+	o := openElement{name: tok.Name}
+	if p.rec != nil {
+		raw := p.raw()
+		end := bytes.IndexAny(raw, " \t\r\n/>")
+		if len(raw) > 0 && end > 0 {
+			o.qname = string(raw[1:end])
+		}
+	}
+	if p.rec != nil || p.opts.Limits.MaxNameLength > 0 {
+		for _, a := range tok.Attr {
+			if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+				o.ns = append(o.ns, a)
+			}
+		}
+	}
+	return o
+}
+
+// recovery is the state of a parser that recovers from errors.  After an
+// error, a new decoder is started on the rest of the input.  Its input
+// begins with synthetic start tags for the open elements, so that it knows
+// their names and namespaces.
+type recovery struct {
+	input    []byte        // the whole input, after cleaning
+	edits    []edit        // the changes made to the input by cleaning
+	problems []*ParseError // found so far
+	pending  []xml.Token   // end tags invented to close elements
+	resume   Position      // the position in input at which the decoder started
+	prefix   int           // the length of the synthetic start tags
+	skip     int           // the number of synthetic start tags not yet read
+	done     bool          // there is nothing more to parse
+}
+
+// edit records that offsets in the cleaned input from clean onwards are
+// delta less than in the original input.
+type edit struct {
+	clean, delta int64
+}
+
+func (opts ParseOptions) newRecoveringParser(r io.Reader) *parser {
+	p := &parser{opts: opts.normalised()}
+	if p.opts.Limits.MaxInputBytes > 0 {
+		r = newLimitedReader(r, p.opts.Limits.MaxInputBytes)
+		p.limitedInput = true
+	}
+	r, _ = sniffCharset(r)
+	data, err := io.ReadAll(r)
+	p.recent = &recentInput{buf: data}
+	p.rec = &recovery{}
+	p.rec.clean(p, data)
+	if err != nil {
+		p.rec.problems = append(p.rec.problems, &ParseError{Position: advance(Position{Line: 1, Column: 1}, data), Err: err})
+	}
+	p.restart(Position{Line: 1, Column: 1}, nil)
+	return p
+}
+
+// restart starts a new decoder at pos in the input, with the given elements open.
+func (p *parser) restart(pos Position, open []openElement) {
+	var prefix bytes.Buffer
+	for _, o := range open {
+		prefix.WriteString("<" + o.qname)
+		for _, a := range o.ns {
+			if a.Name.Space == "" {
+				prefix.WriteString(` xmlns="`)
+			} else {
+				prefix.WriteString(` xmlns:` + a.Name.Local + `="`)
+			}
+			_ = xml.EscapeText(&prefix, []byte(a.Value))
+			prefix.WriteString(`"`)
+		}
+		prefix.WriteString(">")
+	}
+
+	p.rec.resume = pos
+	p.rec.prefix = prefix.Len()
+	p.rec.skip = len(open)
+	p.decoder = newStrictDecoder(io.MultiReader(&prefix, bytes.NewReader(p.rec.input[pos.Offset:])))
+	p.decoder.CharsetReader = func(name string, input io.Reader) (io.Reader, error) {
+		return input, nil // already converted to UTF-8
+	}
+}
+
+// recover records the problem and arranges for parsing to carry on.
+func (p *parser) recover(pe *ParseError) {
+	rec := p.rec
+	rec.problems = append(rec.problems, pe)
+
+	at := p.decoderPos()
+	var se *xml.SyntaxError
+	switch {
+	case !errors.As(pe.Err, &se):
+		p.closeAll()
+
+	case strings.Contains(se.Msg, " closed by </") || strings.HasPrefix(se.Msg, "unexpected end element"):
+		// close the elements inside the one that the end tag matches, if any
+		name := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(string(p.raw()), "</"), ">"))
+		for i := len(p.open) - 1; i >= 0; i-- {
+			if p.open[i].qname == name || (p.open[i].qname == "" && p.open[i].name.Local == name) {
+				for j := len(p.open) - 1; j >= i; j-- {
+					rec.pending = append(rec.pending, xml.EndElement{Name: p.open[j].name})
+				}
+				p.restart(at, p.open[:i])
+				return
+			}
+		}
+		p.restart(at, p.open)
+
+	case at.Offset >= int64(len(rec.input)):
+		p.closeAll()
+
+	default:
+		// skip to the next markup, which may have been read already
+		from := max(at.Offset, p.start+1)
+		if at.Offset > p.start+1 && rec.input[at.Offset-1] == '<' {
+			at.Offset--
+			at.Column--
+			from = at.Offset
+		}
+		next := bytes.IndexByte(rec.input[from:], '<')
+		if next < 0 {
+			p.closeAll()
+			return
+		}
+		p.restart(advance(at, rec.input[at.Offset:from+int64(next)]), p.open)
+	}
+}
+
+// closeAll ends parsing, closing the open elements.
+func (p *parser) closeAll() {
+	for i := len(p.open) - 1; i >= 0; i-- {
+		p.rec.pending = append(p.rec.pending, xml.EndElement{Name: p.open[i].name})
+	}
+	p.rec.done = true
+}
+
+// absolute converts a position given by the decoder to one in the input.
+func (rec *recovery) absolute(line, column int, offset int64) Position {
+	offset -= int64(rec.prefix)
+	if offset < 0 {
+		return rec.resume
+	}
+	if line == 1 {
+		return Position{Line: rec.resume.Line, Column: rec.resume.Column + column - 1 - rec.prefix, Offset: rec.resume.Offset + offset}
+	}
+	return Position{Line: rec.resume.Line + line - 1, Column: column, Offset: rec.resume.Offset + offset}
+}
+
+// original converts a position in the cleaned input to one in the original input.
+func (rec *recovery) original(pos Position) Position {
+	if len(rec.edits) == 0 || !pos.IsValid() {
+		return pos
+	}
+	lineStart := pos.Offset - int64(pos.Column-1)
+	if lineStart > 0 {
+		// the preceding newline is never edited
+		lineStart = rec.originalOffset(lineStart-1) + 1
+	}
+	pos.Offset = rec.originalOffset(pos.Offset)
+	pos.Column = int(pos.Offset-lineStart) + 1
+	return pos
+}
+
+func (rec *recovery) originalOffset(offset int64) int64 {
+	i := sort.Search(len(rec.edits), func(i int) bool { return rec.edits[i].clean > offset })
+	if i == 0 {
+		return offset
+	}
+	return offset + rec.edits[i-1].delta
+}
+
+// advance returns the position after data, which starts at pos.
+func advance(pos Position, data []byte) Position {
+	for _, b := range data {
+		if b == '\n' {
+			pos.Line++
+			pos.Column = 0
+		}
+		pos.Column++
+		pos.Offset++
+	}
+	return pos
+}
+
+//-------------------------------------------------------------------------------------------------
+
+// clean sets the input to data without the characters that are not allowed
+// in XML, and with each "&" that does not start a reference, and each "<"
+// within an attribute value, escaped.  These are recorded as problems.
+// Comments, CDATA sections, processing instructions and declarations are
+// passed over, except for their characters.
+func (rec *recovery) clean(p *parser, data []byte) {
+	out := make([]byte, 0, len(data))
+	pos := Position{Line: 1, Column: 1}
+	var delta int64
+	end := ""      // the end of the markup being passed over, if any
+	inTag := false // within a start or end tag
+	var quote byte // the quote around the attribute value being read, if any
+
+	for int(pos.Offset) < len(data) {
+		rest := data[pos.Offset:]
+
+		switch {
+		case end != "":
+			if bytes.HasPrefix(rest, []byte(end)) {
+				end = ""
+			}
+		case quote != 0:
+			if rest[0] == quote {
+				quote = 0
+			} else if rest[0] == '<' && !closesFirst(rest[1:], quote, '<') {
+				// the value is not closed, so this starts the next tag
+				quote = 0
+			}
+		case inTag && (rest[0] == '"' || rest[0] == '\''):
+			quote = rest[0]
+		case inTag && rest[0] == '>':
+			inTag = false
+		case bytes.HasPrefix(rest, []byte("<!--")):
+			end = "-->"
+		case bytes.HasPrefix(rest, []byte("<![CDATA[")):
+			end = "]]>"
+		case bytes.HasPrefix(rest, []byte("<?")):
+			end = "?>"
+		case bytes.HasPrefix(rest, []byte("<!")):
+			end = ">"
+		case rest[0] == '<':
+			inTag = true
+		}
+
+		r, size := utf8.DecodeRune(rest)
+		switch {
+		case (r == utf8.RuneError && size == 1) || !isXMLChar(r):
+			err := fmt.Errorf("%w %s", InvalidCharacter, describeInvalid(rest[:size], r))
+			rec.problems = append(rec.problems, &ParseError{Position: pos, Snippet: p.recent.snippet(pos.Offset), Err: err})
+			delta += int64(size)
+			rec.edits = append(rec.edits, edit{clean: int64(len(out)), delta: delta})
+
+		case r == '<' && quote != 0:
+			err := fmt.Errorf("%w %q in attribute value", InvalidCharacter, "<")
+			rec.problems = append(rec.problems, &ParseError{Position: pos, Snippet: p.recent.snippet(pos.Offset), Err: err})
+			out = append(out, "&lt;"...)
+			delta -= 3
+			rec.edits = append(rec.edits, edit{clean: int64(len(out)), delta: delta})
+
+		case r == '&' && end == "" && !isReference(rest):
+			err := fmt.Errorf("%w %q", InvalidReference, referenceText(rest))
+			rec.problems = append(rec.problems, &ParseError{Position: pos, Snippet: p.recent.snippet(pos.Offset), Err: err})
+			out = append(out, "&amp;"...)
+			delta -= 4
+			rec.edits = append(rec.edits, edit{clean: int64(len(out)), delta: delta})
+
+		default:
+			out = append(out, rest[:size]...)
+		}
+		pos = advance(pos, rest[:size])
+	}
+
+	rec.input = out
+}
+
+// closesFirst returns true if quote occurs in s before stop.
+func closesFirst(s []byte, quote, stop byte) bool {
+	q := bytes.IndexByte(s, quote)
+	return q >= 0 && !slices.Contains(s[:q], stop)
+}
+
+// isXMLChar returns true for the characters allowed in XML 1.0.
+func isXMLChar(r rune) bool {
+	return r == 0x09 || r == 0x0A || r == 0x0D ||
+		r >= 0x20 && r <= 0xD7FF ||
+		r >= 0xE000 && r <= 0xFFFD ||
+		r >= 0x10000 && r <= 0x10FFFF
+}
+
+func describeInvalid(b []byte, r rune) string {
+	if r == utf8.RuneError && len(b) == 1 {
+		return fmt.Sprintf("byte 0x%02X (invalid UTF-8)", b[0])
+	}
+	return fmt.Sprintf("U+%04X", r)
+}
+
+// isReference returns true if s starts with a well-formed reference, e.g. "&amp;" or "&#38;".
+func isReference(s []byte) bool {
+	end := bytes.IndexByte(s[:min(len(s), 12)], ';')
+	if end < 0 {
+		return false
+	}
+	name := s[1:end]
+	switch string(name) {
+	case "lt", "gt", "amp", "apos", "quot":
+		return true
+	}
+
+	digits, base := name, 10
+	if bytes.HasPrefix(digits, []byte("#x")) {
+		digits, base = digits[2:], 16
+	} else if bytes.HasPrefix(digits, []byte("#")) {
+		digits = digits[1:]
+	} else {
+		return false
+	}
+	if len(digits) == 0 || len(digits) > 8 {
+		return false
+	}
+	var r rune
+	for _, c := range digits {
+		var d rune
+		switch {
+		case c >= '0' && c <= '9':
+			d = rune(c - '0')
+		case base == 16 && c >= 'a' && c <= 'f':
+			d = rune(c-'a') + 10
+		case base == 16 && c >= 'A' && c <= 'F':
+			d = rune(c-'A') + 10
+		default:
+			return false
+		}
+		r = r*rune(base) + d
+	}
+	return isXMLChar(r)
+}
+
+// referenceText returns the text that looks like a reference at the start of s.
+func referenceText(s []byte) string {
+	if end := bytes.IndexAny(s[1:], "; \t\r\n<&"); end >= 0 && end < 20 && s[end+1] == ';' {
+		return string(s[:end+2])
+	}
+	return "&"
+}
@@ -0,0 +1,123 @@
+package dom
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func problemSummary(problems []*ParseError) []string {
+	var res []string
+	for _, pe := range problems {
+		res = append(res, pe.Position.String()+" "+pe.Error())
+	}
+	return res
+}
+
+func TestParseRecoverWellFormed(t *testing.T) {
+	doc, problems := ParseRecover(strings.NewReader(testDoc))
+	expect.Slice(problems).ToBeEmpty(t)
+
+	want, err := ParseString(testDoc)
+	expect.Error(err).ToBeNil(t)
+	expect.String(doc.String()).ToBe(t, want.String())
+}
+
+func TestParseRecover(t *testing.T) {
+	const input = "<list>\n" +
+		"  <item id=\"1\">AT&T \x01ok</item>\n" +
+		"  <item id=\"2\"><name>two</item>\n" +
+		"  <item id=\"3\">three</itm>\n" +
+		"  </stray>\n" +
+		"  <item id=\"4\">four</item>\n" +
+		"  <item id=\"5\"><sub>five"
+
+	doc, problems := ParseOptions{Positions: true}.ParseRecover(strings.NewReader(input))
+	expect.Slice(problemSummary(problems)).ToBe(t,
+		`2:18 invalid character or entity reference "&" at line 2, column 18`,
+		`2:21 invalid character U+0001 at line 2, column 21`,
+		`3:31 XML syntax error: element <name> closed by </item> at line 3, column 31 in /list/item/name`,
+		`4:26 XML syntax error: element <item> closed by </itm> at line 4, column 26 in /list/item`,
+		`5:10 XML syntax error: element <item> closed by </stray> at line 5, column 10 in /list/item`,
+		`7:24 XML syntax error: unexpected EOF at line 7, column 24 in /list/item/item/sub`,
+	)
+
+	// </itm> and </stray> match nothing, so the third item is left open
+	items := doc.Root().Children()
+	expect.Number(len(items)).ToBe(t, 3)
+	expect.String(items[0].Content).ToEqual(t, "AT&T ok")
+	expect.String(items[1].ChildAt(0).Content).ToEqual(t, "two")
+	expect.String(items[2].Content).ToEqual(t, "three")
+
+	four := items[2].ChildAt(0)
+	expect.String(four.Content).ToEqual(t, "four")
+	expect.String(four.Position().String()).ToBe(t, "6:3")
+	expect.String(items[2].ChildAt(1).ChildAt(0).Content).ToEqual(t, "five")
+
+	expect.String(problems[1].Snippet).ToBe(t, "  <item id=\"1\">AT&T \x01ok</item>\n                    ^")
+}
+
+func TestParseRecoverNamespaces(t *testing.T) {
+	const input = `<s:Envelope xmlns:s="urn:s"><s:Body xmlns="urn:d"><a><b></a><c/></s:Body></s:Envelope>`
+
+	doc, problems := ParseRecover(strings.NewReader(input))
+	expect.Number(len(problems)).ToBe(t, 1)
+	body := doc.Root().ChildAt(0)
+	expect.String(body.Name.Space).ToBe(t, "urn:s")
+	expect.Number(len(body.Children())).ToBe(t, 2)
+	expect.String(body.ChildAt(1).Name.Space).ToBe(t, "urn:d")
+	expect.String(body.ChildAt(1).Name.Local).ToBe(t, "c")
+}
+
+func TestParseRecoverSkips(t *testing.T) {
+	doc, problems := ParseRecover(strings.NewReader(`<a><b x=1>text</b><c/></a><d/>`))
+	expect.Slice(problemSummary(problems)).ToBe(t,
+		"1:9 XML syntax error: unquoted or missing attribute value in element at line 1, column 9 in /a",
+		"1:18 XML syntax error: element <a> closed by </b> at line 1, column 18 in /a",
+		"1:27 no more than one root element is allowed: found <d> at line 1, column 27",
+	)
+	expect.String(doc.Root().Bytes()).ToEqual(t, `<a><c/></a>`)
+
+	// limits end the parse
+	doc, problems = ParseOptions{Limits: Limits{MaxDepth: 2}}.ParseRecover(strings.NewReader(`<a><b><c/></b></a>`))
+	expect.Number(len(problems)).ToBe(t, 1)
+	expect.Bool(errors.Is(problems[0], DepthLimitExceeded)).ToBeTrue(t)
+	expect.String(doc.Root().Bytes()).ToEqual(t, `<a><b/></a>`)
+
+	// an unclosed attribute value ends at the next tag
+	doc, problems = ParseRecover(strings.NewReader(`<a b="x><c/></a>`))
+	expect.Slice(problemSummary(problems)).ToBe(t,
+		"1:9 XML syntax error: unescaped < inside quoted string at line 1, column 9",
+		"1:16 XML syntax error: unexpected end element </a> at line 1, column 16",
+	)
+	expect.String(doc.Root().Bytes()).ToEqual(t, `<c/>`)
+}
+
+func TestParseRecoverAttrValues(t *testing.T) {
+	doc, problems := ParseRecover(strings.NewReader(`<a b="<" c='x<y>z'/>`))
+	expect.Slice(problemSummary(problems)).ToBe(t,
+		`1:7 invalid character "<" in attribute value at line 1, column 7`,
+		`1:14 invalid character "<" in attribute value at line 1, column 14`,
+	)
+	expect.String(doc.Root().Bytes()).ToEqual(t, `<a b="&lt;" c="x&lt;y>z"/>`)
+	expect.String(doc.Root().AttrValue("c", "")).ToBe(t, "x<y>z")
+
+	// markup within attribute values is not markup
+	doc, problems = ParseRecover(strings.NewReader(`<a b="<!--">&bad;</a>`))
+	expect.Number(len(problems)).ToBe(t, 2)
+	expect.String(doc.Root().Content).ToEqual(t, "&bad;")
+}
+
+func TestParseRecoverNoRoot(t *testing.T) {
+	for _, input := range []string{"", "garbage", "<!-- only -->\n"} {
+		doc, problems := ParseRecover(strings.NewReader(input))
+		expect.Any(doc.Root()).I(input).ToBeNil(t)
+		expect.Number(len(problems)).I(input).ToBe(t, 1)
+		expect.Bool(errors.Is(problems[0], NoRootElement)).I(input).ToBeTrue(t)
+	}
+
+	_, problems := ParseRecover(strings.NewReader("garbage"))
+	expect.String(problems[0].Error()).ToBe(t, "no root element at line 1, column 8")
+}
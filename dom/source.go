@@ -0,0 +1,64 @@
+package dom
+
+import (
+	"bufio"
+	"io"
+)
+
+// source records the raw input consumed by an [xml.Decoder], so that the
+// exact bytes of each token can be inspected, e.g. to tell a CDATA section
+// from other text.  Because source is an [io.ByteReader], the decoder reads
+// from it directly without buffering ahead.
+type source struct {
+	r    io.ByteReader
+	buf  []byte
+	base int64 // the input offset of buf[0]
+}
+
+func newSource(r io.Reader) *source {
+	br, ok := r.(io.ByteReader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	return &source{r: br}
+}
+
+func (s *source) ReadByte() (byte, error) {
+	b, err := s.r.ReadByte()
+	if err == nil {
+		s.buf = append(s.buf, b)
+	}
+	return b, err
+}
+
+func (s *source) Read(p []byte) (n int, err error) {
+	for n < len(p) {
+		p[n], err = s.ReadByte()
+		if err != nil {
+			return n, err
+		}
+		n++
+	}
+	return n, nil
+}
+
+// bytes returns the recorded input between two offsets.  Input that has
+// already been discarded is omitted.
+func (s *source) bytes(from, to int64) []byte {
+	from = max(from-s.base, 0)
+	to = min(to-s.base, int64(len(s.buf)))
+	if from >= to {
+		return nil
+	}
+	return s.buf[from:to]
+}
+
+// discard forgets the recorded input before offset.
+func (s *source) discard(offset int64) {
+	n := int(offset - s.base)
+	// shifting the buffer is deferred until it is worth it
+	if n > 0 && n >= len(s.buf)/2 {
+		s.buf = s.buf[:copy(s.buf, s.buf[n:])]
+		s.base = offset
+	}
+}
@@ -0,0 +1,129 @@
+package dom
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// StopStream can be returned by the callback of [Stream] to stop streaming
+// without an error.
+var StopStream = errors.New("stop streaming")
+
+// Stream reads a document element by element, without building the whole
+// tree.  Each element for which match returns true is parsed, including all
+// its descendants, and passed to fn; it is then discarded.  So memory use is
+// bounded by the size of the largest matching element rather than by the
+// size of the document.  For example, to process the records of a large feed:
+//
+//	match, _ := MatchPath("/feed/record")
+//	err := Stream(r, match, func(record *Element) error { ... })
+//
+// match is called for the start of every element outside those that have
+// matched.  At that point, the element it is given has its name and
+// attributes but no children or content.  Its ancestors are available using
+// [Element.Parent] but they too have no children or content, so the positions
+// in its [Element.Path] are not meaningful.  A search.Match can be used as the
+// match function.
+//
+// The element passed to fn has no parent.  Streaming stops when fn returns an
+// error, which is returned by Stream unless it is [StopStream].
+func Stream(r io.Reader, match func(*Element) bool, fn func(*Element) error) error {
+	return ParseOptions{}.Stream(r, match, fn)
+}
+
+// Stream is like [Stream] but with these options, which apply to the elements
+// passed to fn.
+func (opts ParseOptions) Stream(r io.Reader, match func(*Element) bool, fn func(*Element) error) error {
+	err := opts.newParser(r).stream(match, fn)
+	if errors.Is(err, StopStream) {
+		return nil
+	}
+	return err
+}
+
+// StreamWithDecoder is like [ParseOptions.Stream] but the decoder options can be specified.
+func (opts ParseOptions) StreamWithDecoder(decoder *xml.Decoder, match func(*Element) bool, fn func(*Element) error) error {
+	p := &parser{decoder: decoder, opts: opts.normalised()}
+	err := p.stream(match, fn)
+	if errors.Is(err, StopStream) {
+		return nil
+	}
+	return err
+}
+
+func (p *parser) stream(match func(*Element) bool, fn func(*Element) error) error {
+	var open *Element // the innermost unmatched element
+	for {
+		tok, err := p.token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch rt := tok.(type) {
+		case xml.StartElement:
+			skeleton := CreateElement(rt.Name)
+			skeleton.Attributes = rt.Attr
+			skeleton.parent = open
+			if !match(skeleton) {
+				open = skeleton
+				continue
+			}
+
+			e, err := p.parseElement(rt, p.inheritedWhitespace(skeleton))
+			if err != nil {
+				return err
+			}
+			if err := fn(e); err != nil {
+				return err
+			}
+
+		case xml.EndElement:
+			if open != nil {
+				open = open.parent
+			}
+		}
+	}
+}
+
+// MatchPath returns a match function for [Stream] that matches elements by
+// their path.  The path is a list of names such as "/feed/record", which only
+// matches record elements that are children of the root feed element, or
+// "//record", which matches record elements at any depth.  Each name may be
+// a local name, which matches elements in any namespace, "{uri}local", or "*"
+// to match any element.  Positions are not supported.
+func MatchPath(path string) (func(*Element) bool, error) {
+	anywhere := len(path) > 2 && path[:2] == "//"
+	if anywhere {
+		path = path[1:]
+	}
+	steps, err := splitPath(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range steps {
+		if strings.HasSuffix(s.raw, "]") {
+			return nil, fmt.Errorf("%w: positions are not supported: %q", InvalidPath, path)
+		}
+	}
+
+	return func(e *Element) bool {
+		for i := len(steps) - 1; i >= 0; i-- {
+			if e == nil || !steps[i].matches(e.Name) {
+				return false
+			}
+			e = e.parent
+		}
+		return anywhere || e == nil
+	}, nil
+}
+
+func (s pathStep) matches(name xml.Name) bool {
+	return (s.name.Local == "*" || s.name.Local == name.Local) &&
+		(!s.qualified || s.name.Space == name.Space)
+}
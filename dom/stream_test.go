@@ -0,0 +1,70 @@
+package dom
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+const feed = `<feed xmlns:x="urn:x">
+  <header><record id="h"/></header>
+  <record id="1"><name>one</name><record id="nested"/></record>
+  <x:record id="2"><name>two</name></x:record>
+  <other><record id="3"/></other>
+</feed>`
+
+func streamIDs(t *testing.T, path string) []string {
+	t.Helper()
+	match, err := MatchPath(path)
+	expect.Error(err).ToBeNil(t)
+
+	var ids []string
+	err = Stream(strings.NewReader(feed), match, func(e *Element) error {
+		expect.Any(e.Parent()).ToBeNil(t)
+		id, _ := e.AttrValue("id", "")
+		ids = append(ids, id)
+		return nil
+	})
+	expect.Error(err).ToBeNil(t)
+	return ids
+}
+
+func TestStreamMatchPath(t *testing.T) {
+	expect.Slice(streamIDs(t, "/feed/record")).ToBe(t, "1", "2")
+	expect.Slice(streamIDs(t, "/feed/{urn:x}record")).ToBe(t, "2")
+	expect.Slice(streamIDs(t, "//record")).ToBe(t, "h", "1", "2", "3")
+	expect.Slice(streamIDs(t, "/feed/*/record")).ToBe(t, "h", "nested", "3")
+	expect.Slice(streamIDs(t, "/record")).ToBe(t)
+
+	for _, path := range []string{"", "feed", "//", "/feed/record[2]"} {
+		_, err := MatchPath(path)
+		expect.Bool(errors.Is(err, InvalidPath)).I(path).ToBeTrue(t)
+	}
+}
+
+func TestStreamSubtree(t *testing.T) {
+	var got []string
+	err := ParseOptions{Positions: true}.Stream(strings.NewReader(feed),
+		func(e *Element) bool {
+			return e.Name.Local == "record" && e.HasAttr("id", "") && e.Parent() != nil && e.Parent().Name.Local == "feed"
+		},
+		func(e *Element) error {
+			got = append(got, e.Name.Local+"/"+e.ChildAt(1).Name.Local)
+			expect.Number(e.Position().Line).ToBe(t, 3)
+			return StopStream
+		})
+	expect.Error(err).ToBeNil(t)
+	expect.Slice(got).ToBe(t, "record/record")
+}
+
+func TestStreamErrors(t *testing.T) {
+	failed := errors.New("failed")
+	match, _ := MatchPath("//record")
+	err := Stream(strings.NewReader(feed), match, func(e *Element) error { return failed })
+	expect.Bool(errors.Is(err, failed)).ToBeTrue(t)
+
+	err = Stream(strings.NewReader(`<feed><record></feed>`), match, func(e *Element) error { return nil })
+	expect.Error(err).Not().ToBeNil(t)
+}
@@ -0,0 +1,90 @@
+package dom
+
+// WalkAction tells [Walk] how to carry on after visiting an element.
+type WalkAction int
+
+const (
+	// Continue visits the children of the element, then carries on as normal.
+	Continue WalkAction = iota
+	// SkipChildren carries on without visiting the children of the element.
+	// When returned by a leave callback, it is the same as Continue.
+	SkipChildren
+	// Stop ends the walk immediately.
+	Stop
+)
+
+// WalkFunc is called by [Walk] for each element visited.  The depth of the
+// root element is zero.
+type WalkFunc func(e *Element, depth int) WalkAction
+
+// Walk calls fn for root and each of its descendants in document order, i.e.
+// each element before its children.  The result of fn controls whether the
+// children are visited and whether the walk carries on; see [WalkAction].
+//
+// The tree may be altered by fn:
+//
+//   - The children of e may be changed freely; those present when fn returns
+//     are the ones visited.
+//   - e may be removed from its parent, or replaced.  Its children are then not
+//     visited, and the walk carries on with the element that is now in its
+//     place, or after it.  So removing elements does not cause their following
+//     siblings to be skipped, but a replacement element is visited.  If e is
+//     moved amongst its siblings, the walk carries on after its new position.
+//   - Siblings after e may be added or removed; the walk visits those present
+//     when it gets to them.  Siblings before e may be removed too, along with
+//     e itself, without its following siblings being skipped.  Other changes
+//     before e, or elsewhere in the tree, are not visited.
+func Walk(root *Element, fn WalkFunc) {
+	WalkEnterLeave(root, fn, nil)
+}
+
+// WalkEnterLeave is like [Walk] except that leave is also called for each
+// element after its children have been visited, or after they have been
+// skipped.  Every element that enter is called for is also passed to leave,
+// unless the walk is stopped first.  Either function may be nil.
+//
+// The tree may be altered by either function, as described for [Walk].  In
+// leave, e may also be removed or replaced: the walk carries on with the
+// element that is then in its place, or after it.
+func WalkEnterLeave(root *Element, enter, leave WalkFunc) {
+	walk(root, 0, enter, leave)
+}
+
+// walk visits e and its descendants, returning false if the walk is to stop.
+func walk(e *Element, depth int, enter, leave WalkFunc) bool {
+	parent := e.parent
+	action := Continue
+	if enter != nil {
+		action = enter(e, depth)
+	}
+	if action == Stop {
+		return false
+	}
+
+	if action != SkipChildren && e.parent == parent {
+		for i := 0; i < len(e.children); {
+			c := e.children[i]
+			prev, next := c.PrevSibling(), c.NextSibling()
+			if !walk(c, depth+1, enter, leave) {
+				return false
+			}
+			// if c was removed or replaced, carry on with whatever is now in
+			// its place, i.e. after prev, or else at next
+			switch {
+			case c.parent == e:
+				i = c.index + 1
+			case prev == nil:
+				i = 0
+			case prev.parent == e:
+				i = prev.index + 1
+			case next != nil && next.parent == e:
+				i = next.index
+			}
+		}
+	}
+
+	if leave != nil && leave(e, depth) == Stop {
+		return false
+	}
+	return true
+}
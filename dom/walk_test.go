@@ -0,0 +1,114 @@
+package dom
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestWalk(t *testing.T) {
+	a := iterTree(t)
+	var visited []string
+	Walk(a, func(e *Element, depth int) WalkAction {
+		visited = append(visited, fmt.Sprintf("%s%d", e.Name.Local, depth))
+		if e.Name.Local == "b" {
+			return SkipChildren
+		}
+		return Continue
+	})
+	expect.Slice(visited).ToBe(t, "a0", "b1", "c1", "f2")
+}
+
+func TestWalkStop(t *testing.T) {
+	a := iterTree(t)
+	var visited []string
+	Walk(a, func(e *Element, depth int) WalkAction {
+		visited = append(visited, e.Name.Local)
+		if e.Name.Local == "e" {
+			return Stop
+		}
+		return Continue
+	})
+	expect.Slice(visited).ToBe(t, "a", "b", "d", "e")
+}
+
+func TestWalkEnterLeave(t *testing.T) {
+	a := iterTree(t)
+	var events []string
+	WalkEnterLeave(a,
+		func(e *Element, depth int) WalkAction {
+			events = append(events, "<"+e.Name.Local)
+			if e.Name.Local == "c" {
+				return SkipChildren
+			}
+			return Continue
+		},
+		func(e *Element, depth int) WalkAction {
+			events = append(events, e.Name.Local+">")
+			return Continue
+		})
+	expect.String(strings.Join(events, " ")).ToBe(t, "<a <b <d d> <e e> b> <c c> a>")
+}
+
+func TestWalkRemoving(t *testing.T) {
+	root := mustParse(t, `<r><script/><script/><p><script/><i/></p><script/><q/></r>`)
+	var visited []string
+	Walk(root, func(e *Element, depth int) WalkAction {
+		visited = append(visited, e.Name.Local)
+		if e.Name.Local == "script" {
+			e.Parent().RemoveChild(e)
+			e.AddChild(Elem("never", ""))
+		}
+		return Continue
+	})
+	expect.Slice(visited).ToBe(t, "r", "script", "script", "p", "script", "i", "script", "q")
+	expect.String(root.Bytes()).ToEqual(t, "<r><p><i/></p><q/></r>")
+}
+
+func TestWalkRemovingOnLeave(t *testing.T) {
+	root := mustParse(t, `<r><p><x/></p><p/><q/></r>`)
+	var visited []string
+	WalkEnterLeave(root, nil, func(e *Element, depth int) WalkAction {
+		visited = append(visited, e.Name.Local)
+		if e.Name.Local == "p" && e.ChildCount() == 0 {
+			e.Parent().RemoveChild(e)
+		}
+		return Continue
+	})
+	expect.Slice(visited).ToBe(t, "x", "p", "p", "q", "r")
+	expect.String(root.Bytes()).ToEqual(t, "<r><p><x/></p><q/></r>")
+}
+
+func TestWalkReplacingAndInserting(t *testing.T) {
+	root := mustParse(t, `<r><old/><b/></r>`)
+	var visited []string
+	Walk(root, func(e *Element, depth int) WalkAction {
+		visited = append(visited, e.Name.Local)
+		switch e.Name.Local {
+		case "old":
+			e.Parent().ReplaceChild(e, Elem("new", ""))
+		case "b":
+			e.Parent().InsertAfter(e, Elem("c", ""))
+			e.AddChild(Elem("d", ""))
+		}
+		return Continue
+	})
+	expect.Slice(visited).ToBe(t, "r", "old", "new", "b", "d", "c")
+}
+
+func TestWalkRemovingEarlierSiblings(t *testing.T) {
+	root := mustParse(t, `<r><a/><b/><c/><d/></r>`)
+	var visited []string
+	Walk(root, func(e *Element, depth int) WalkAction {
+		visited = append(visited, e.Name.Local)
+		if e.Name.Local == "b" {
+			root.RemoveChild(root.ChildAt(0))
+			root.RemoveChild(e)
+		}
+		return Continue
+	})
+	expect.Slice(visited).ToBe(t, "r", "a", "b", "c", "d")
+	expect.String(root.Bytes()).ToEqual(t, "<r><c/><d/></r>")
+}
@@ -0,0 +1,88 @@
+package dom
+
+// WhitespacePolicy controls how whitespace in text is treated when parsing;
+// see [ParseOptions] Whitespace.  It follows the whiteSpace facet of XML
+// Schema.  Within elements that have xml:space="preserve", and their
+// descendants, whitespace is always preserved; xml:space="default" restores
+// the policy in the [ParseOptions].
+type WhitespacePolicy int
+
+const (
+	// TrimWhitespace removes leading and trailing whitespace from the Content
+	// of elements, and drops text that is only whitespace.  With
+	// MixedContent, text is kept verbatim, except for indentation, i.e. text
+	// that is only whitespace and includes a line break.  This is the default.
+	TrimWhitespace WhitespacePolicy = iota
+
+	// PreserveWhitespace keeps text verbatim, including text that is only
+	// whitespace.  Without MixedContent, such text is only kept in the
+	// Content of elements that have no child elements, because otherwise it
+	// is indentation.
+	PreserveWhitespace
+
+	// CollapseWhitespace replaces each run of whitespace with a single space,
+	// then trims the Content of elements.  With MixedContent, text is not
+	// trimmed, so that words either side of child elements stay apart, but
+	// indentation is dropped.
+	CollapseWhitespace
+)
+
+// whitespace returns the policy for the content of e, given that inherited
+// from its parent.
+func (p *parser) whitespace(e *Element, inherited WhitespacePolicy) WhitespacePolicy {
+	if ws, found := p.xmlSpace(e); found {
+		return ws
+	}
+	return inherited
+}
+
+// inheritedWhitespace returns the policy that e inherits from its ancestors.
+func (p *parser) inheritedWhitespace(e *Element) WhitespacePolicy {
+	for a := range e.AncestorsSeq() {
+		if ws, found := p.xmlSpace(a); found {
+			return ws
+		}
+	}
+	return p.opts.Whitespace
+}
+
+// xmlSpace returns the policy given by the xml:space attribute of e, if it has one.
+func (p *parser) xmlSpace(e *Element) (WhitespacePolicy, bool) {
+	switch v, _ := e.AttrValue("space", xmlURL); v {
+	case "preserve":
+		return PreserveWhitespace, true
+	case "default":
+		return p.opts.Whitespace, true
+	}
+	return 0, false
+}
+
+// preservesSpace returns true if the whitespace in the content of node must
+// not be altered, i.e. it has xml:space="preserve".
+func (node *Element) preservesSpace() bool {
+	v, _ := node.AttrValue("space", xmlURL)
+	return v == "preserve"
+}
+
+// collapseSpace replaces each run of whitespace in b with a single space, as
+// for the XML Schema whiteSpace facet "collapse".  Leading and trailing
+// whitespace is removed if trim is true.
+func collapseSpace(b []byte, trim bool) []byte {
+	res := make([]byte, 0, len(b))
+	space := false
+	for _, c := range b {
+		if c == ' ' || c == '\t' || c == '\n' || c == '\r' {
+			space = true
+			continue
+		}
+		if space && (len(res) > 0 || !trim) {
+			res = append(res, ' ')
+		}
+		space = false
+		res = append(res, c)
+	}
+	if space && !trim {
+		res = append(res, ' ')
+	}
+	return res
+}
@@ -0,0 +1,93 @@
+package dom
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestWhitespacePolicy(t *testing.T) {
+	const input = `<a>
+  <pwd>  s3cret </pwd>
+  <blank>   </blank>
+  <text> several
+     words	here </text>
+  <pre xml:space="preserve"> kept <b>  as is  </b></pre>
+</a>`
+
+	cases := []struct {
+		ws                       WhitespacePolicy
+		pwd, blank, text, pre, b string
+	}{
+		{TrimWhitespace, "s3cret", "", "several\n     words\there", " kept ", "  as is  "},
+		{PreserveWhitespace, "  s3cret ", "   ", " several\n     words\there ", " kept ", "  as is  "},
+		{CollapseWhitespace, "s3cret", "", "several words here", " kept ", "  as is  "},
+	}
+
+	for _, c := range cases {
+		doc, err := ParseOptions{Whitespace: c.ws}.Parse(strings.NewReader(input))
+		expect.Error(err).ToBeNil(t)
+		a := doc.Root()
+		expect.String(a.Content).ToEqual(t, "")
+		expect.String(a.ChildAt(0).Content).ToEqual(t, c.pwd)
+		expect.String(a.ChildAt(1).Content).ToEqual(t, c.blank)
+		expect.String(a.ChildAt(2).Content).ToEqual(t, c.text)
+		expect.String(a.ChildAt(3).Content).ToEqual(t, c.pre)
+		expect.String(a.ChildAt(3).ChildAt(0).Content).ToEqual(t, c.b)
+	}
+}
+
+func TestWhitespacePolicyMixedContent(t *testing.T) {
+	const input = `<p>
+  Hello   <b> big </b>
+  <i>wide</i> world <q xml:space="preserve"> <x/> </q>
+</p>`
+
+	cases := []struct {
+		ws   WhitespacePolicy
+		want string
+	}{
+		{TrimWhitespace, "\n  Hello   | world | big |wide| | "},
+		{PreserveWhitespace, "\n  Hello   |\n  | world |\n| big |wide| | "},
+		{CollapseWhitespace, " Hello | world | big |wide| | "},
+	}
+
+	for _, c := range cases {
+		doc, err := ParseOptions{MixedContent: true, Whitespace: c.ws}.Parse(strings.NewReader(input))
+		expect.Error(err).ToBeNil(t)
+		var texts []string
+		for e := range doc.Root().PreOrder() {
+			for _, n := range e.nodes {
+				if text, ok := n.(Text); ok {
+					texts = append(texts, string(text))
+				}
+			}
+		}
+		expect.String(strings.Join(texts, "|")).I(c.ws).ToBe(t, c.want)
+	}
+}
+
+func TestWhitespaceStream(t *testing.T) {
+	const input = `<feed xml:space="preserve"><record> a </record><x xml:space="default"><record> b </record></x></feed>`
+	match, _ := MatchPath("//record")
+	var got []string
+	err := Stream(strings.NewReader(input), match, func(e *Element) error {
+		got = append(got, string(e.Content))
+		return nil
+	})
+	expect.Error(err).ToBeNil(t)
+	expect.Slice(got).ToBe(t, " a ", "b")
+}
+
+func TestEncodePreservedSpace(t *testing.T) {
+	doc, err := ParseString(`<a><pre xml:space="preserve"><b>x</b><c>y</c></pre><d><e/></d></a>`)
+	expect.Error(err).ToBeNil(t)
+	expect.String(doc.Root().String()).ToBe(t, `<a>
+  <pre xml:space="preserve"><b>x</b><c>y</c></pre>
+  <d>
+    <e/>
+  </d>
+</a>
+`)
+}
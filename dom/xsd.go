@@ -0,0 +1,156 @@
+package dom
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The lexical forms of the XML Schema simple types, as used in attribute
+// values and element content.  Leading and trailing whitespace is ignored when
+// parsing.
+
+// parseBool parses an xs:boolean, which is one of "true", "false", "1" or "0".
+func parseBool(s string) (bool, error) {
+	switch strings.TrimSpace(s) {
+	case "true", "1":
+		return true, nil
+	case "false", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("invalid xs:boolean %q", s)
+}
+
+// parseInt parses an xs:integer or any of its subtypes.
+func parseInt(s string) (int, error) {
+	return strconv.Atoi(strings.TrimSpace(s))
+}
+
+// parseFloat parses an xs:double, xs:float or xs:decimal.
+func parseFloat(s string) (float64, error) {
+	s = strings.TrimSpace(s)
+	switch s {
+	case "INF", "+INF", "-INF", "NaN":
+	default:
+		if strings.ContainsAny(s, "iInNxXpP_") {
+			// reject the other spellings accepted by strconv, e.g. "inf",
+			// hexadecimal such as "0x1p4" and underscores
+			return 0, fmt.Errorf("invalid xs:double %q", s)
+		}
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+// The layouts of xs:dateTime and xs:date, with and without a timezone.
+var timeLayouts = []string{
+	"2006-01-02T15:04:05.999999999Z07:00",
+	"2006-01-02T15:04:05.999999999",
+	"2006-01-02Z07:00",
+	"2006-01-02",
+}
+
+// parseTime parses an xs:dateTime or xs:date.  Values without a timezone are
+// treated as UTC.
+func parseTime(s string) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid xs:dateTime %q", s)
+}
+
+// formatFloat writes the canonical form of an xs:double, e.g. "1.5E2": the
+// mantissa has one digit before the point and at least one after it, and the
+// exponent has no "+" sign or leading zeros.
+func formatFloat(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "INF"
+	case math.IsInf(f, -1):
+		return "-INF"
+	}
+
+	mantissa, exp, _ := strings.Cut(strconv.FormatFloat(f, 'E', -1, 64), "E")
+	if !strings.Contains(mantissa, ".") {
+		mantissa += ".0"
+	}
+	e, _ := strconv.Atoi(exp)
+	return mantissa + "E" + strconv.Itoa(e)
+}
+
+// formatTime writes the canonical form of an xs:dateTime, which is in UTC.
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeLayouts[0])
+}
+
+var durationRE = regexp.MustCompile(`^(-)?P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseDuration parses an xs:duration.  Because years and months vary in
+// length, they cannot be converted to a [time.Duration] and must be zero.
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+	m := durationRE.FindStringSubmatch(s)
+	if m == nil || s == "P" || s == "-P" || strings.HasSuffix(s, "T") {
+		return 0, fmt.Errorf("invalid xs:duration %q", s)
+	}
+	if (m[2] != "" && strings.Trim(m[2], "0") != "") || (m[3] != "" && strings.Trim(m[3], "0") != "") {
+		return 0, fmt.Errorf("xs:duration %q has years or months, so cannot be a time.Duration", s)
+	}
+
+	days, _ := strconv.ParseInt("0"+m[4], 10, 64)
+	hours, _ := strconv.ParseInt("0"+m[5], 10, 64)
+	d, err := time.ParseDuration(fmt.Sprintf("%dh%sm%ss", days*24+hours, "0"+m[6], "0"+m[7]))
+	if err != nil {
+		return 0, fmt.Errorf("invalid xs:duration %q: %w", s, err)
+	}
+	if m[1] != "" {
+		d = -d
+	}
+	return d, nil
+}
+
+// formatDuration writes the canonical form of an xs:duration, e.g. "P1DT2H30M".
+func formatDuration(d time.Duration) string {
+	var b strings.Builder
+	if d < 0 {
+		b.WriteByte('-')
+		d = -d
+	}
+	b.WriteByte('P')
+
+	if days := d / (24 * time.Hour); days > 0 {
+		fmt.Fprintf(&b, "%dD", days)
+		d -= days * 24 * time.Hour
+	}
+	if d == 0 {
+		if b.Len() <= 2 {
+			b.WriteString("T0S")
+		}
+		return b.String()
+	}
+
+	b.WriteByte('T')
+	if hours := d / time.Hour; hours > 0 {
+		fmt.Fprintf(&b, "%dH", hours)
+		d -= hours * time.Hour
+	}
+	if minutes := d / time.Minute; minutes > 0 {
+		fmt.Fprintf(&b, "%dM", minutes)
+		d -= minutes * time.Minute
+	}
+	if d > 0 {
+		fmt.Fprintf(&b, "%d", d/time.Second)
+		if nanos := d % time.Second; nanos > 0 {
+			b.WriteString(strings.TrimRight(fmt.Sprintf(".%09d", nanos), "0"))
+		}
+		b.WriteByte('S')
+	}
+	return b.String()
+}
@@ -0,0 +1,61 @@
+package dom
+
+import (
+	"math"
+	"testing"
+
+	"github.com/rickb777/expect"
+)
+
+func TestFormatFloat(t *testing.T) {
+	cases := []struct {
+		f    float64
+		want string
+	}{
+		{0, "0.0E0"},
+		{math.Copysign(0, -1), "-0.0E0"},
+		{1, "1.0E0"},
+		{150, "1.5E2"},
+		{-150, "-1.5E2"},
+		{1e21, "1.0E21"},
+		{1e-5, "1.0E-5"},
+		{0.1, "1.0E-1"},
+		{123.456, "1.23456E2"},
+		{math.MaxFloat64, "1.7976931348623157E308"},
+		{math.SmallestNonzeroFloat64, "5.0E-324"},
+		{math.Inf(1), "INF"},
+		{math.Inf(-1), "-INF"},
+		{math.NaN(), "NaN"},
+	}
+
+	for _, c := range cases {
+		s := formatFloat(c.f)
+		expect.String(s).I(c.f).ToBe(t, c.want)
+
+		if !math.IsNaN(c.f) {
+			f, err := parseFloat(s)
+			expect.Error(err).ToBeNil(t)
+			expect.Number(f).I(s).ToBe(t, c.f)
+		}
+	}
+}
+
+func TestParseNumbers(t *testing.T) {
+	for _, s := range []string{"5", "+5", " -5 ", "007"} {
+		_, err := parseInt(s)
+		expect.Error(err).I(s).ToBeNil(t)
+	}
+	for _, s := range []string{"+-5", "++5", "-+5", "5.0", "0x10", "1_0", ""} {
+		_, err := parseInt(s)
+		expect.Error(err).I(s).Not().ToBeNil(t)
+	}
+
+	for _, s := range []string{"1", "+1.5", "-1.5E2", ".5e-3", "INF", "-INF", "NaN"} {
+		_, err := parseFloat(s)
+		expect.Error(err).I(s).ToBeNil(t)
+	}
+	for _, s := range []string{"0x1p4", "0X1P4", "1_0", "1p4", "inf", "Infinity", "nan", "++1", ""} {
+		_, err := parseFloat(s)
+		expect.Error(err).I(s).Not().ToBeNil(t)
+	}
+}
@@ -2,11 +2,13 @@ module github.com/rickb777/simplexml
 
 go 1.24.1
 
-require github.com/magefile/mage v1.15.0
+require (
+	github.com/magefile/mage v1.15.0
+	github.com/rickb777/expect v1.0.6
+)
 
 require (
 	github.com/google/go-cmp v0.7.0 // indirect
-	github.com/rickb777/expect v1.0.6 // indirect
 	github.com/rickb777/plural v1.4.7 // indirect
 )
 
@@ -0,0 +1,332 @@
+// Package patch applies XML Patch operations (RFC 5261) to documents from the
+// simplexml/dom package.
+//
+// A patch document has a root element (conventionally <diff>) containing any
+// number of <add>, <replace> and <remove> operations, each with a sel
+// attribute that selects exactly one node of the target document.  For
+// example
+//
+//	<diff xmlns:s="http://schemas.xmlsoap.org/soap/envelope/">
+//	  <add sel="/s:Envelope/s:Body" pos="prepend"><first/></add>
+//	  <replace sel="/s:Envelope/s:Body/item[2]/@id">x</replace>
+//	  <remove sel="//item[@id='y']"/>
+//	</diff>
+//
+// The selectors are restricted XPath location paths; see [Operation].
+package patch
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/rickb777/simplexml/dom"
+)
+
+var (
+	NoMatch          = errors.New("selector matches nothing")
+	MultipleMatches  = errors.New("selector matches more than one node")
+	InvalidSelector  = errors.New("invalid selector")
+	InvalidOperation = errors.New("invalid patch operation")
+	Unsupported      = errors.New("unsupported patch operation")
+)
+
+// Error reports a patch operation that could not be parsed or applied.
+type Error struct {
+	// Index is the position of the operation in the patch, counting from zero.
+	Index int
+	// Op is the name of the operation, i.e. "add", "replace" or "remove".
+	Op  string
+	Sel string
+	Err error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("patch: %s %q: %v", e.Op, e.Sel, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Operation is one <add>, <replace> or <remove> element of a patch document.
+//
+// Sel selects the node to be changed.  It is a location path such as
+//
+//	/a/p:b[2]/c[@id='x']
+//	//c[@id][1]
+//	/a/b/@attr
+//	/a/b/text()
+//
+// in which each step may have predicates that are a 1-based position, an
+// attribute test [@name] or an attribute value test [@name='value'], and
+// element names may be "*".  Prefixes are resolved using the namespace
+// declarations in scope for the operation and, as required by RFC 5261,
+// unprefixed element names are in its default namespace.
+type Operation struct {
+	// Op is "add", "replace" or "remove".
+	Op  string
+	Sel string
+	// Pos is the position for "add": "before", "after", "prepend", or empty to append.
+	Pos string
+	// Type is "@name" when "add" adds an attribute.
+	Type string
+	// WS is the whitespace handling for "remove"; since the dom package does
+	// not hold whitespace between elements, it has no effect.
+	WS string
+
+	elem *dom.Element
+	sel  *selector
+}
+
+// Patch is a parsed patch document.
+type Patch struct {
+	Operations []Operation
+}
+
+// Parse reads a patch document.  Text, comments and processing instructions
+// within the operations are kept so that they can be added to the target.
+func Parse(r io.Reader) (*Patch, error) {
+	doc, err := dom.ParseOptions{MixedContent: true, PreserveMarkup: true}.Parse(r)
+	if err != nil {
+		return nil, err
+	}
+	return New(doc)
+}
+
+// New creates a patch from a patch document that has already been parsed.
+// The document should have been parsed with [dom.ParseOptions] MixedContent
+// and PreserveMarkup if text or comments are to be added.
+func New(doc *dom.Document) (*Patch, error) {
+	if doc.Root() == nil {
+		return nil, InvalidOperation
+	}
+
+	p := &Patch{}
+	for i, e := range doc.Root().Children() {
+		op := Operation{
+			Op:   e.Name.Local,
+			Sel:  attrValue(e, "sel"),
+			Pos:  attrValue(e, "pos"),
+			Type: attrValue(e, "type"),
+			WS:   attrValue(e, "ws"),
+			elem: e,
+		}
+		if err := op.validate(); err != nil {
+			return nil, &Error{Index: i, Op: op.Op, Sel: op.Sel, Err: err}
+		}
+		p.Operations = append(p.Operations, op)
+	}
+	return p, nil
+}
+
+func (op *Operation) validate() error {
+	switch op.Op {
+	case "add":
+		if !slices.Contains([]string{"", "before", "after", "prepend"}, op.Pos) {
+			return InvalidOperation
+		}
+		if op.Type != "" && !strings.HasPrefix(op.Type, "@") {
+			return Unsupported
+		}
+	case "replace":
+	case "remove":
+		if !slices.Contains([]string{"", "none", "before", "after", "both"}, op.WS) {
+			return InvalidOperation
+		}
+	default:
+		return InvalidOperation
+	}
+
+	var err error
+	op.sel, err = parseSelector(op.Sel, op.elem)
+	return err
+}
+
+func attrValue(e *dom.Element, name string) string {
+	for _, a := range e.Attributes {
+		if a.Name.Space == "" && a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// Apply applies the patch to doc.  The patch is atomic: either all of the
+// operations succeed, or doc is left unchanged and an [*Error] describes the
+// first operation that failed.  The operations are first tried on a copy of
+// doc, so elements of doc that are held by the caller stay in the document
+// unless the patch removes or replaces them.
+func (p *Patch) Apply(doc *dom.Document) error {
+	if err := p.apply(doc.Clone()); err != nil {
+		return err
+	}
+	return p.apply(doc)
+}
+
+func (p *Patch) apply(doc *dom.Document) error {
+	for i := range p.Operations {
+		op := &p.Operations[i]
+		if err := op.apply(doc); err != nil {
+			return &Error{Index: i, Op: op.Op, Sel: op.Sel, Err: err}
+		}
+	}
+	return nil
+}
+
+// Apply reads a patch document from r and applies it to doc.
+func Apply(doc *dom.Document, r io.Reader) error {
+	p, err := Parse(r)
+	if err != nil {
+		return err
+	}
+	return p.Apply(doc)
+}
+
+func (op *Operation) apply(doc *dom.Document) error {
+	targets := op.sel.evaluate(doc)
+	switch len(targets) {
+	case 0:
+		return NoMatch
+	case 1:
+	default:
+		return MultipleMatches
+	}
+
+	t := targets[0]
+	switch op.Op {
+	case "add":
+		return op.add(t)
+	case "replace":
+		return op.replace(doc, t)
+	default:
+		return op.remove(t)
+	}
+}
+
+func (op *Operation) add(t target) error {
+	if t.attr != nil || t.text {
+		return InvalidOperation
+	}
+
+	if op.Type != "" {
+		name, err := resolve(op.Type[1:], op.elem, false)
+		if err != nil {
+			return err
+		}
+		if t.elem.HasAttr(name.Local, name.Space) {
+			return InvalidOperation
+		}
+		t.elem.SetAttr(name.Local, name.Space, string(op.elem.Text()))
+		return nil
+	}
+
+	nodes := op.content()
+	if op.Pos == "" {
+		for _, n := range nodes {
+			t.elem.AddNode(n)
+		}
+		return nil
+	}
+
+	// the nodes are inserted in order, starting at position at
+	into, at := t.elem, 0
+	if op.Pos != "prepend" {
+		into = t.elem.Parent()
+		if into == nil {
+			// the root cannot have siblings
+			return InvalidOperation
+		}
+		at = slices.Index(into.Nodes(), dom.Node(t.elem))
+		if op.Pos == "after" {
+			at++
+		}
+	}
+	for _, n := range nodes {
+		into.InsertNodeAt(at, n)
+		at++
+	}
+	return nil
+}
+
+func (op *Operation) replace(doc *dom.Document, t target) error {
+	switch {
+	case t.attr != nil:
+		t.elem.SetAttr(t.attr.Local, t.attr.Space, string(op.elem.Text()))
+		return nil
+
+	case t.text:
+		t.elem.SetText(string(op.elem.Text()))
+		return nil
+	}
+
+	// other nodes, such as comments, are ignored
+	var replacement *dom.Element
+	for _, n := range op.content() {
+		if child, ok := n.(*dom.Element); ok {
+			if replacement != nil {
+				return InvalidOperation
+			}
+			replacement = child
+		}
+	}
+	if replacement == nil {
+		return InvalidOperation
+	}
+
+	if parent := t.elem.Parent(); parent != nil {
+		parent.ReplaceChild(t.elem, replacement)
+	} else {
+		doc.SetRoot(replacement)
+	}
+	return nil
+}
+
+func (op *Operation) remove(t target) error {
+	if len(op.elem.Nodes()) > 0 || len(op.elem.Text()) > 0 {
+		return InvalidOperation
+	}
+
+	switch {
+	case t.attr != nil:
+		t.elem.RemoveAttr(t.attr.Local, t.attr.Space)
+		return nil
+
+	case t.text:
+		t.elem.SetText("")
+		return nil
+	}
+
+	parent := t.elem.Parent()
+	if parent == nil {
+		return InvalidOperation
+	}
+	parent.RemoveChild(t.elem)
+	return nil
+}
+
+// content returns copies of the nodes within the operation, ignoring any
+// text that is only whitespace.
+func (op *Operation) content() []dom.Node {
+	var res []dom.Node
+	for _, n := range op.elem.Nodes() {
+		switch t := n.(type) {
+		case dom.Text:
+			if len(bytes.TrimSpace(t)) > 0 {
+				res = append(res, dom.Text(bytes.Clone(t)))
+			}
+		case dom.CData:
+			res = append(res, dom.CData(bytes.Clone(t)))
+		case dom.Comment:
+			res = append(res, dom.Comment(bytes.Clone(t)))
+		case dom.ProcInst:
+			res = append(res, dom.ProcInst{Target: t.Target, Inst: bytes.Clone(t.Inst)})
+		case *dom.Element:
+			res = append(res, t.Clone())
+		}
+	}
+	return res
+}
@@ -0,0 +1,143 @@
+package patch
+
+import (
+	"encoding/xml"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rickb777/expect"
+	"github.com/rickb777/simplexml/dom"
+)
+
+const targetDoc = `<s:Envelope xmlns:s="urn:s"><s:Body><item id="x" n="1">one</item><item id="y">two</item></s:Body></s:Envelope>`
+
+func parse(t *testing.T, s string) *dom.Document {
+	t.Helper()
+	doc, err := dom.ParseString(s)
+	expect.Error(err).ToBeNil(t)
+	return doc
+}
+
+func apply(t *testing.T, doc *dom.Document, ops string) error {
+	t.Helper()
+	return Apply(doc, strings.NewReader(`<diff xmlns:p="urn:s">`+ops+`</diff>`))
+}
+
+// body returns the encoded Body element, as written within its Envelope.
+func body(doc *dom.Document) string {
+	s := strings.TrimPrefix(string(doc.Root().Bytes()), `<s:Envelope xmlns:s="urn:s">`)
+	return strings.TrimSuffix(s, `</s:Envelope>`)
+}
+
+func TestAddElements(t *testing.T) {
+	doc := parse(t, targetDoc)
+	err := apply(t, doc, `
+		<add sel="/p:Envelope/p:Body" pos="prepend"><first/><second/></add>
+		<add sel="/p:Envelope/p:Body"><last>z</last></add>
+		<add sel="//item[@id='y']" pos="before"><b/></add>
+		<add sel="//item[@id='y']" pos="after"><a1/><a2/></add>`)
+	expect.Error(err).ToBeNil(t)
+	expect.String(body(doc)).ToBe(t,
+		`<s:Body><first/><second/><item id="x" n="1">one</item><b/><item id="y">two</item><a1/><a2/><last>z</last></s:Body>`)
+}
+
+func TestAddAttributeAndText(t *testing.T) {
+	doc := parse(t, targetDoc)
+	err := apply(t, doc, `
+		<add sel="/p:Envelope/p:Body/item[2]" type="@n">2</add>
+		<add sel="/p:Envelope/p:Body/item[1]">!</add>`)
+	expect.Error(err).ToBeNil(t)
+	expect.String(body(doc)).ToBe(t, `<s:Body><item id="x" n="1">one!</item><item id="y" n="2">two</item></s:Body>`)
+}
+
+func TestAddOtherNodes(t *testing.T) {
+	doc := parse(t, targetDoc)
+	err := apply(t, doc, `
+		<add sel="//item[@id='y']" pos="before">between<!--c--></add>
+		<add sel="//item[@id='y']" pos="after"><?pi x?>end</add>
+		<add sel="//item[@id='x']" pos="prepend">zero <![CDATA[&]]></add>`)
+	expect.Error(err).ToBeNil(t)
+	expect.String(body(doc)).ToBe(t,
+		`<s:Body><item id="x" n="1">zero <![CDATA[&]]>one</item>between<!--c--><item id="y">two</item><?pi x?>end</s:Body>`)
+}
+
+func TestReplace(t *testing.T) {
+	doc := parse(t, targetDoc)
+	err := apply(t, doc, `
+		<replace sel="/p:Envelope/p:Body/item[1]/@n">9</replace>
+		<replace sel="/p:Envelope/p:Body/item[2]/text()">deux</replace>
+		<replace sel="//item[@id='x']"><!--new--><thing/><?pi?></replace>`)
+	expect.Error(err).ToBeNil(t)
+	expect.String(body(doc)).ToBe(t, `<s:Body><thing/><item id="y">deux</item></s:Body>`)
+}
+
+func TestRemove(t *testing.T) {
+	doc := parse(t, targetDoc)
+	err := apply(t, doc, `
+		<remove sel="/p:Envelope/p:Body/item[1]/@n"/>
+		<remove sel="/p:Envelope/p:Body/item[1]/text()"/>
+		<remove sel="//*[@id='y']"/>`)
+	expect.Error(err).ToBeNil(t)
+	expect.String(body(doc)).ToBe(t, `<s:Body><item id="x"/></s:Body>`)
+}
+
+func TestReplaceRoot(t *testing.T) {
+	doc := parse(t, targetDoc)
+	expect.Error(apply(t, doc, `<replace sel="/*"><root/></replace>`)).ToBeNil(t)
+	expect.String(doc.Root().Bytes()).ToEqual(t, `<root/>`)
+}
+
+func TestApplyKeepsElements(t *testing.T) {
+	doc := parse(t, targetDoc)
+	doc.SetIDAttrs(xml.Name{Local: "id"})
+	y, err := doc.ElementByID("y")
+	expect.Error(err).ToBeNil(t)
+
+	expect.Error(apply(t, doc, `<remove sel="//item[@id='x']"/>`)).ToBeNil(t)
+	expect.Bool(y.Parent() == doc.Root().ChildAt(0)).ToBeTrue(t)
+
+	y.SetText("changed")
+	expect.String(body(doc)).ToEqual(t, `<s:Body><item id="y">changed</item></s:Body>`)
+}
+
+func TestApplyIsAtomic(t *testing.T) {
+	doc := parse(t, targetDoc)
+	err := apply(t, doc, `
+		<remove sel="//item[@id='x']"/>
+		<remove sel="//item[@id='x']"/>`)
+
+	var pe *Error
+	expect.Bool(errors.As(err, &pe)).ToBeTrue(t)
+	expect.Number(pe.Index).ToBe(t, 1)
+	expect.String(pe.Op).ToBe(t, "remove")
+	expect.Bool(errors.Is(err, NoMatch)).ToBeTrue(t)
+	expect.String(err.Error()).ToBe(t, `patch: remove "//item[@id='x']": selector matches nothing`)
+	expect.String(doc.Root().Bytes()).ToEqual(t, targetDoc)
+}
+
+func TestErrors(t *testing.T) {
+	cases := []struct {
+		ops string
+		err error
+	}{
+		{`<remove sel="//item"/>`, MultipleMatches},
+		{`<remove sel="/p:Envelope/p:Body/item[3]"/>`, NoMatch},
+		{`<remove sel="/q:Envelope"/>`, dom.UndeclaredPrefix},
+		{`<remove sel="/p:Envelope/[1]"/>`, InvalidSelector},
+		{`<remove sel="/@x"/>`, InvalidSelector},
+		{`<replace sel="/@x">1</replace>`, InvalidSelector},
+		{`<add sel="//@x" type="@y">1</add>`, InvalidSelector},
+		{`<remove sel="/p:Envelope"/>`, InvalidOperation},
+		{`<move sel="/p:Envelope"/>`, InvalidOperation},
+		{`<add sel="/p:Envelope" type="namespace::q">urn:q</add>`, Unsupported},
+		{`<add sel="/p:Envelope" pos="after"><x/></add>`, InvalidOperation},
+		{`<replace sel="//item[1]"><a/><b/></replace>`, InvalidOperation},
+	}
+
+	for _, c := range cases {
+		doc := parse(t, targetDoc)
+		err := apply(t, doc, c.ops)
+		expect.Bool(errors.Is(err, c.err)).I(c.ops).ToBeTrue(t)
+	}
+}
@@ -0,0 +1,297 @@
+package patch
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/rickb777/simplexml/dom"
+	"github.com/rickb777/simplexml/search"
+)
+
+// The sel attribute of a patch operation is a restricted XPath expression.
+// The supported forms are location paths such as
+//
+//	/a/p:b[2]/c[@id='x']
+//	//c[@id][1]
+//	/a/b/@attr
+//	/a/b/text()
+//
+// where each step may have any number of predicates that are either a
+// 1-based position, an attribute test [@name] or an attribute value test
+// [@name='value'].  Element names may be "*".
+
+// selector is a parsed sel expression.
+type selector struct {
+	steps []step
+	// attr is set when the selector ends with an attribute step.
+	attr *xml.Name
+	// text is set when the selector ends with text().
+	text bool
+}
+
+type step struct {
+	descendant bool
+	local      string
+	space      string
+	predicates []predicate
+}
+
+// predicate either filters the candidates, or picks one by position.
+type predicate struct {
+	match    search.Match
+	position int
+}
+
+// target is the node selected by a selector.
+type target struct {
+	elem *dom.Element
+	attr *xml.Name
+	text bool
+}
+
+// parseSelector parses sel, resolving prefixes in the scope of the operation element op.
+func parseSelector(sel string, op *dom.Element) (*selector, error) {
+	s := &selector{}
+	rest := strings.TrimSpace(sel)
+	if rest == "" || rest == "/" {
+		return nil, InvalidSelector
+	}
+
+	for rest != "" {
+		st := step{}
+		switch {
+		case strings.HasPrefix(rest, "//"):
+			st.descendant = true
+			rest = rest[2:]
+		case strings.HasPrefix(rest, "/"):
+			rest = rest[1:]
+		case len(s.steps) > 0:
+			return nil, InvalidSelector
+		}
+
+		end := stepEnd(rest)
+		text := rest[:end]
+		rest = rest[end:]
+
+		switch {
+		case strings.HasPrefix(text, "@"):
+			if rest != "" || st.descendant || len(s.steps) == 0 {
+				return nil, InvalidSelector
+			}
+			name, err := resolve(text[1:], op, false)
+			if err != nil {
+				return nil, err
+			}
+			s.attr = &name
+			return s, nil
+
+		case text == "text()" || text == "text()[1]":
+			if rest != "" || st.descendant || len(s.steps) == 0 {
+				return nil, InvalidSelector
+			}
+			s.text = true
+			return s, nil
+		}
+
+		name, preds, _ := strings.Cut(text, "[")
+		qname, err := resolve(name, op, true)
+		if err != nil {
+			return nil, err
+		}
+		st.local, st.space = qname.Local, qname.Space
+		if preds != "" {
+			st.predicates, err = parsePredicates("["+preds, op)
+			if err != nil {
+				return nil, err
+			}
+		}
+		s.steps = append(s.steps, st)
+	}
+
+	if len(s.steps) == 0 {
+		return nil, InvalidSelector
+	}
+	return s, nil
+}
+
+// stepEnd finds the end of the first step in s, ignoring "/" within predicates.
+func stepEnd(s string) int {
+	depth := 0
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == '[':
+			depth++
+		case c == ']':
+			depth--
+		case c == '/' && depth == 0:
+			return i
+		}
+	}
+	return len(s)
+}
+
+func parsePredicates(s string, op *dom.Element) ([]predicate, error) {
+	var preds []predicate
+	for s != "" {
+		if s[0] != '[' {
+			return nil, InvalidSelector
+		}
+		body, rest, found := cutPredicate(s[1:])
+		if !found {
+			return nil, InvalidSelector
+		}
+		s = rest
+
+		if n, err := strconv.Atoi(body); err == nil {
+			if n < 1 {
+				return nil, InvalidSelector
+			}
+			preds = append(preds, predicate{position: n})
+			continue
+		}
+
+		attr, ok := strings.CutPrefix(body, "@")
+		if !ok {
+			return nil, InvalidSelector
+		}
+		value := "*"
+		if name, literal, hasValue := strings.Cut(attr, "="); hasValue {
+			literal = strings.TrimSpace(literal)
+			if len(literal) < 2 || (literal[0] != '\'' && literal[0] != '"') || literal[len(literal)-1] != literal[0] {
+				return nil, InvalidSelector
+			}
+			attr, value = strings.TrimSpace(name), literal[1:len(literal)-1]
+		}
+		name, err := resolve(attr, op, false)
+		if err != nil {
+			return nil, err
+		}
+		preds = append(preds, predicate{match: search.Attr(name.Local, name.Space, value)})
+	}
+	return preds, nil
+}
+
+// cutPredicate splits "body]rest" at the closing bracket, skipping quoted literals.
+func cutPredicate(s string) (body, rest string, found bool) {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ']':
+			return strings.TrimSpace(s[:i]), s[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// resolve converts a QName in the selector to an expanded name.  As required
+// by RFC 5261, unprefixed element names are in the default namespace of the
+// patch operation, whereas unprefixed attribute names have no namespace.
+func resolve(qname string, op *dom.Element, isElement bool) (xml.Name, error) {
+	if qname == "" {
+		return xml.Name{}, InvalidSelector
+	}
+	if qname == "*" {
+		return xml.Name{Space: "*", Local: "*"}, nil
+	}
+	prefix, local, found := strings.Cut(qname, ":")
+	if !found {
+		if isElement {
+			uri, _ := op.LookupNamespaceURI("")
+			return xml.Name{Space: uri, Local: qname}, nil
+		}
+		return xml.Name{Local: qname}, nil
+	}
+	uri, ok := op.LookupNamespaceURI(prefix)
+	if !ok || local == "" {
+		return xml.Name{}, fmt.Errorf("%w %q in selector", dom.UndeclaredPrefix, prefix)
+	}
+	return xml.Name{Space: uri, Local: local}, nil
+}
+
+// evaluate finds the nodes matched by the selector in doc.
+func (s *selector) evaluate(doc *dom.Document) []target {
+	// a nil element stands for the document node
+	context := []*dom.Element{nil}
+	for _, st := range s.steps {
+		if st.descendant {
+			context = selfAndDescendants(doc, context)
+		}
+		var next []*dom.Element
+		seen := make(map[*dom.Element]bool)
+		for _, c := range context {
+			for _, e := range st.apply(children(doc, c)) {
+				if !seen[e] {
+					seen[e] = true
+					next = append(next, e)
+				}
+			}
+		}
+		context = next
+	}
+
+	res := make([]target, 0, len(context))
+	for _, e := range context {
+		if s.attr == nil || search.Attr(s.attr.Local, s.attr.Space, "*")(e) {
+			res = append(res, target{elem: e, attr: s.attr, text: s.text})
+		}
+	}
+	return res
+}
+
+func (st step) apply(nodes []*dom.Element) []*dom.Element {
+	nodes = search.All(search.Tag(st.local, st.space), nodes)
+	for _, p := range st.predicates {
+		if p.match != nil {
+			nodes = search.All(p.match, nodes)
+		} else if p.position <= len(nodes) {
+			nodes = nodes[p.position-1 : p.position]
+		} else {
+			nodes = nil
+		}
+	}
+	return nodes
+}
+
+// children returns the children of e, where nil is the document node.
+func children(doc *dom.Document, e *dom.Element) []*dom.Element {
+	if e != nil {
+		return e.Children()
+	}
+	if doc.Root() == nil {
+		return nil
+	}
+	return []*dom.Element{doc.Root()}
+}
+
+// selfAndDescendants expands each context node to include its descendants.
+func selfAndDescendants(doc *dom.Document, context []*dom.Element) []*dom.Element {
+	var res []*dom.Element
+	for _, c := range context {
+		if c == nil {
+			res = append(res, nil)
+			if doc.Root() != nil {
+				res = append(res, doc.Root().All()...)
+			}
+		} else {
+			res = append(res, c.All()...)
+		}
+	}
+	return res
+}
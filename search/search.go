@@ -4,6 +4,7 @@ package search
 
 import (
 	"bytes"
+	"iter"
 	"regexp"
 
 	"github.com/rickb777/simplexml/dom"
@@ -61,7 +62,7 @@ func NoParent() Match {
 // ancestor that matches the passed matcher
 func Ancestor(fn Match) Match {
 	return func(e *dom.Element) bool {
-		return First(fn, e.Ancestors()) != nil
+		return FirstSeq(fn, e.AncestorsSeq()) != nil
 	}
 }
 
@@ -98,12 +99,7 @@ func Parent(fn Match) Match {
 // child that matches the passed fn.
 func Child(fn Match) Match {
 	return func(e *dom.Element) bool {
-		for _, c := range e.Children() {
-			if fn(c) {
-				return true
-			}
-		}
-		return false
+		return FirstSeq(fn, e.ChildrenSeq()) != nil
 	}
 }
 
@@ -140,6 +136,29 @@ func First(fn Match, nodes []*dom.Element) *dom.Element {
 	return nil
 }
 
+// Seq returns an iterator over the elements of seq that fn matches.
+// Elements are only taken from seq as they are needed.
+func Seq(fn Match, seq iter.Seq[*dom.Element]) iter.Seq[*dom.Element] {
+	return func(yield func(*dom.Element) bool) {
+		for n := range seq {
+			if fn(n) && !yield(n) {
+				return
+			}
+		}
+	}
+}
+
+// FirstSeq returns the first element in seq that fn matches, or nil if there is none.
+// Unlike First, it stops as soon as a match is found, e.g.
+//
+//	FirstSeq(Tag("item", "*"), root.PreOrder())
+func FirstSeq(fn Match, seq iter.Seq[*dom.Element]) *dom.Element {
+	for n := range Seq(fn, seq) {
+		return n
+	}
+	return nil
+}
+
 // Tag is a helper function for matching against a specific tag.
 // It takes a name and a namespace URL to match against.
 // If either name or space are "*", then they will match
@@ -157,11 +176,17 @@ func FirstTag(name, space string, nodes []*dom.Element) *dom.Element {
 	return First(Tag(name, space), nodes)
 }
 
-// MustFirstTag is the same as FirstTag, but it panics if the tag cannot be found
+// MustFirstTag is the same as FirstTag, but it panics if the tag cannot be found.
+// The panic message gives the location of the first of the nodes, if any;
+// see [dom.Element.Location].
 func MustFirstTag(name, space string, nodes []*dom.Element) *dom.Element {
 	res := FirstTag(name, space, nodes)
 	if res == nil {
-		panic("Failed to find tag " + name + " in namespace " + space)
+		msg := "Failed to find tag " + name + " in namespace " + space
+		if len(nodes) > 0 {
+			msg += " from " + nodes[0].Location()
+		}
+		panic(msg)
 	}
 	return res
 }
@@ -209,24 +234,27 @@ func AttrRE(name, space, value *regexp.Regexp) Match {
 
 // ContentExists creates a Match against an element that has non-empty
 // Content.
+// For elements with mixed content, the text is as given by [dom.Element.Text].
 func ContentExists() Match {
 	return func(e *dom.Element) bool {
-		return len(e.Content) > 0
+		return len(e.Text()) > 0
 	}
 }
 
 // Content creates a Match against an element that tests to see if
 // it matches the supplied content.
+// For elements with mixed content, the text is as given by [dom.Element.Text].
 func Content(content []byte) Match {
 	return func(e *dom.Element) bool {
-		return bytes.Equal(e.Content, content)
+		return bytes.Equal(e.Text(), content)
 	}
 }
 
 // ContentRE creates a Match against the Content of am element
 // that passes if the regex matches the content.
+// For elements with mixed content, the text is as given by [dom.Element.Text].
 func ContentRE(regex *regexp.Regexp) Match {
 	return func(e *dom.Element) bool {
-		return regex.Match(e.Content)
+		return regex.Match(e.Text())
 	}
 }
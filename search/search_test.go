@@ -283,3 +283,53 @@ func TestNever(t *testing.T) {
 		t.Error("Never returned true")
 	}
 }
+
+func TestContentMixed(t *testing.T) {
+	doc, err := dom.ParseOptions{MixedContent: true}.Parse(strings.NewReader(`<p>Hello <b>big</b> world</p>`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := All(Content([]byte("Hello  world")), doc.Root().All())
+	if len(res) != 1 || res[0] != doc.Root() {
+		t.Errorf("Expected to match the p element, got %d elements", len(res))
+	}
+	res = All(ContentRE(regexp.MustCompile("^big$")), doc.Root().All())
+	if len(res) != 1 || res[0].Name.Local != "b" {
+		t.Errorf("Expected to match the b element, got %d elements", len(res))
+	}
+}
+
+func TestSeq(t *testing.T) {
+	doc := parseDoc()
+	var idx []string
+	for e := range Seq(Tag("node2", ""), doc.Root().PreOrder()) {
+		v, _ := e.AttrValue("idx", "")
+		idx = append(idx, v)
+	}
+	if strings.Join(idx, ",") != "2,5,3" {
+		t.Errorf("Expected node2 elements in document order, got %v", idx)
+	}
+}
+
+func TestFirstSeq(t *testing.T) {
+	doc := parseDoc()
+	visited := 0
+	seq := func(yield func(*dom.Element) bool) {
+		for e := range doc.Root().PreOrder() {
+			visited++
+			if !yield(e) {
+				return
+			}
+		}
+	}
+	res := FirstSeq(Attr("idx", "", "4"), seq)
+	if res == nil || res.Name.Local != "sub" {
+		t.Fatalf("Could not find sub element")
+	}
+	if visited != 3 {
+		t.Errorf("Expected to stop after 3 elements, visited %d", visited)
+	}
+	if FirstSeq(Tag("missing", "*"), doc.Root().PreOrder()) != nil {
+		t.Errorf("Expected no match")
+	}
+}